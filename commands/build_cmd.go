@@ -7,12 +7,26 @@ import (
 )
 
 var BuildCmd = cli.Command{
-	Name:         "build",
-	Usage:        "build a docker image for the current project",
-	Flags:        dockerRunFlags,
-	BashComplete: bashCompleteRunArgs,
+	Name:  "build",
+	Usage: "build a docker image for the current project",
+	Flags: append(
+		[]cli.Flag{
+			cli.BoolFlag{Name: "force, f", Usage: "Build even if the dependency manifests did not change"},
+			cli.BoolFlag{Name: "no-commit", Usage: "Keep the build container instead of commiting it (see `devstep diff`)"},
+		},
+		dockerRunFlags...,
+	),
+	BashComplete: func(c *cli.Context) {
+		bashCompleteRunArgs(c)
+		if len(c.Args()) == 0 {
+			fmt.Println("--force")
+			fmt.Println("--no-commit")
+		}
+	},
 	Action: func(c *cli.Context) {
 		runOpts := parseRunOpts(c)
+		project.Config().ForceBuild = c.Bool("force")
+		project.Config().NoCommit = c.Bool("no-commit")
 		err := project.Build(client, runOpts)
 		if err != nil {
 			fmt.Println(err)
@@ -3,12 +3,19 @@ package commands
 import (
 	"fmt"
 	"github.com/codegangsta/cli"
+	"github.com/fgrehm/devstep-cli/devstep"
 	"os"
 )
 
 var CommitCmd = cli.Command{
 	Name:  "commit",
 	Usage: "commits the currently running container into a Docker image (EXPERIMENTAL)",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "tag, t", Usage: "commit a named snapshot instead of updating the environment image"},
+		cli.StringFlag{Name: "message, m", Usage: "commit message"},
+		cli.StringFlag{Name: "author, a", Usage: "author of the commit (like 'John Doe <john@example.com>')"},
+		cli.BoolTFlag{Name: "pause, p", Usage: "pause the container while commiting, use --pause=false to commit without pausing"},
+	},
 	Action: func(c *cli.Context) {
 		containerName := os.Getenv("DEVSTEP_CONTAINER_NAME")
 		if containerName == "" {
@@ -16,7 +23,13 @@ var CommitCmd = cli.Command{
 			os.Exit(1)
 		}
 
-		err := project.Commit(client, containerName)
+		pause := c.BoolT("pause")
+		err := project.Commit(client, containerName, &devstep.CommitOpts{
+			Tag:     c.String("tag"),
+			Message: c.String("message"),
+			Author:  c.String("author"),
+			Pause:   &pause,
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var ConfigCmd = cli.Command{
+	Name:  "config",
+	Usage: "inspect the configuration for the current project",
+	Subcommands: []cli.Command{
+		{
+			Name:  "validate",
+			Usage: "check the config files for problems, exiting with an error if any is found",
+			Action: func(c *cli.Context) {
+				if err := newConfigLoader().Validate(); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Println("==> Configuration is valid")
+			},
+		},
+		{
+			Name:  "explain",
+			Usage: "show the final configuration along with where each setting came from",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "json", Usage: "output the settings as JSON"},
+			},
+			Action: func(c *cli.Context) {
+				entries := loadConfig().Explain()
+
+				if c.Bool("json") {
+					data, err := json.MarshalIndent(entries, "", "  ")
+					if err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
+					fmt.Println(string(data))
+					return
+				}
+
+				for _, entry := range entries {
+					fmt.Printf("%-35s %-40s %s\n", entry.Key, entry.Value, entry.Source)
+				}
+			},
+		},
+	},
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/fgrehm/devstep-cli/devstep"
+	"os"
+)
+
+var DiffCmd = cli.Command{
+	Name:  "diff",
+	Usage: "show the changes made to the last build container (or to the provided one) or between two snapshots",
+	Description: `With a single argument (or none) the changes made to a container that would be
+   commited are listed. With two arguments the snapshots are compared, snapshots
+   can be tags of the project repository (see 'devstep snapshots'), 'hack' for
+   the running hack container or container names.
+
+   Example: devstep diff 20160101120000 latest`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "all, a", Usage: "Include the changes that are ignored"},
+		cli.BoolFlag{Name: "json", Usage: "Print the changes as JSON"},
+	},
+	BashComplete: func(c *cli.Context) {
+		if len(c.Args()) >= 2 {
+			return
+		}
+		fmt.Println("hack")
+		snapshots, err := project.Snapshots(client)
+		if err != nil {
+			return
+		}
+		fmt.Println("latest")
+		for _, snapshot := range snapshots {
+			fmt.Println(snapshot.Tag)
+		}
+	},
+	Action: func(c *cli.Context) {
+		if c.Bool("all") {
+			project.Config().IgnoreChanges = []string{}
+		}
+
+		args := c.Args()
+		if len(args) > 2 {
+			fmt.Println("Usage: devstep diff [container | <from> <to>]")
+			os.Exit(1)
+		}
+
+		var (
+			result interface{}
+			err    error
+		)
+		if len(args) == 2 {
+			result, err = project.CompareSnapshots(client, args[0], args[1])
+		} else {
+			result, err = project.Diff(client, args.First())
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if c.Bool("json") {
+			data, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+
+		switch diff := result.(type) {
+		case *devstep.SnapshotDiff:
+			printSnapshotDiff(diff)
+		case *devstep.ContainerDiff:
+			printContainerDiff(diff)
+		}
+	},
+}
+
+func printContainerDiff(diff *devstep.ContainerDiff) {
+	for _, change := range diff.Changes {
+		fmt.Println(change)
+	}
+	if len(diff.Changes) == 0 {
+		fmt.Println("==> No changes, the container would not be commited")
+	}
+	printIgnoredChanges(diff.Ignored)
+}
+
+func printSnapshotDiff(diff *devstep.SnapshotDiff) {
+	fmt.Printf("==> Comparing '%s' with '%s'\n", diff.From, diff.To)
+
+	if len(diff.Files) == 0 {
+		fmt.Println("\n==> No files changed")
+	}
+	for _, dir := range diff.Dirs() {
+		changes := diff.Files[dir]
+		fmt.Printf("\n==> %s (%d changes)\n", dir, len(changes))
+		for _, change := range changes {
+			fmt.Printf("%s\n", change)
+		}
+	}
+
+	if len(diff.Metadata) > 0 {
+		fmt.Println("\n==> Environment and settings")
+		for _, change := range diff.Metadata {
+			switch change.Kind {
+			case devstep.ChangeAdded:
+				fmt.Printf("A %s=%s\n", change.Key, change.To)
+			case devstep.ChangeDeleted:
+				fmt.Printf("D %s=%s\n", change.Key, change.From)
+			default:
+				fmt.Printf("C %s=%s (was %s)\n", change.Key, change.To, change.From)
+			}
+		}
+	}
+	printIgnoredChanges(diff.Ignored)
+}
+
+func printIgnoredChanges(ignored int) {
+	if ignored > 0 {
+		fmt.Printf("==> %d ignored changes (see `ignore_changes` on devstep.yml or use --all)\n", ignored)
+	}
+}
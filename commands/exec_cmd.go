@@ -9,6 +9,9 @@ import (
 var ExecCmd = cli.Command{
 	Name:  "exec",
 	Usage: "Run a one off command against the last container created for the current project",
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "no-tty", Usage: "Run without a pseudo terminal, streaming the command output (default when stdin is not a terminal)"},
+	},
 	Action: func(c *cli.Context) {
 		execCmd := c.Args()
 
@@ -23,10 +26,16 @@ var ExecCmd = cli.Command{
 		// process args
 		execCmd = append([]string{"--"}, execCmd...)
 
-		err := project.Exec(client, execCmd)
+		if c.Bool("no-tty") || !stdinIsTerminal() {
+			project.Config().NoTTY = true
+		}
+
+		result, err := project.Exec(client, execCmd)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+
+		os.Exit(result.ExitCode)
 	},
 }
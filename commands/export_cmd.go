@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var ExportCmd = cli.Command{
+	Name:  "export",
+	Usage: "save the current environment to a tar archive that can be loaded with `devstep import`",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "output, o", Usage: "Write to a file instead of the standard output"},
+	},
+	BashComplete: func(c *cli.Context) {
+		if len(c.Args()) == 0 {
+			fmt.Println("-o")
+			fmt.Println("--output")
+		}
+	},
+	Action: func(c *cli.Context) {
+		output := c.String("output")
+		if output == "" {
+			if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				fmt.Println("Refusing to write the archive to a terminal, use -o <file> or redirect the output")
+				os.Exit(1)
+			}
+			if err := project.Export(client, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("==> Exporting '%s:latest' to '%s'\n", project.Config().RepositoryName, output)
+
+		// Archives are written to a temporary file first so that failed exports
+		// don't leave partial archives behind
+		tmpPath := output + ".tmp"
+		file, err := os.Create(tmpPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		err = project.Export(client, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmpPath, output)
+		}
+		if err != nil {
+			os.Remove(tmpPath)
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
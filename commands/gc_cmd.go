@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/fgrehm/devstep-cli/devstep"
+	"github.com/segmentio/go-prompt"
+	"os"
+	"time"
+)
+
+var GcCmd = cli.Command{
+	Name:  "gc",
+	Usage: "remove containers left behind by devstep for all projects",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "older-than", Usage: "only remove containers created before this period (like '2h' or '7d')"},
+		cli.BoolFlag{Name: "dry-run, n", Usage: "list the containers that would be removed without removing them"},
+		cli.BoolFlag{Name: "force, f", Usage: "skip confirmation"},
+	},
+	BashComplete: func(c *cli.Context) {
+		args := c.Args()
+		if len(args) == 0 {
+			fmt.Println("--older-than")
+			fmt.Println("--dry-run")
+			fmt.Println("--force")
+		}
+	},
+	Action: func(c *cli.Context) {
+		var olderThan time.Duration
+		if age := c.String("older-than"); age != "" {
+			var err error
+			if olderThan, err = devstep.ParseRetentionAge(age); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		orphans, err := devstep.FindOrphanedContainers(client, olderThan)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if len(orphans) == 0 {
+			fmt.Println("No containers to remove")
+			return
+		}
+
+		fmt.Printf("%-30s %-10s %-12s %-6s %s\n", "NAME", "ROLE", "REASON", "AGE", "PROJECT")
+		for _, orphan := range orphans {
+			fmt.Printf("%-30s %-10s %-12s %-6s %s\n",
+				orphan.Name,
+				orphan.Labels[devstep.LabelRole],
+				orphan.Reason,
+				humanDuration(time.Since(orphan.Created)),
+				orphan.Labels[devstep.LabelProject],
+			)
+		}
+
+		if c.Bool("dry-run") {
+			return
+		}
+		if !c.Bool("force") {
+			if ok := prompt.Confirm("Remove %d containers? [y/n]", len(orphans)); !ok {
+				fmt.Println("Aborting")
+				os.Exit(1)
+			}
+		}
+
+		failed := false
+		for _, orphan := range orphans {
+			fmt.Printf("==> Removing '%s'\n", orphan.Name)
+			if err := client.RemoveContainer(orphan.ID); err != nil {
+				fmt.Printf("Error removing container:\n  %s\n", err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
@@ -10,7 +10,10 @@ var HackCmd = cli.Command{
 	Name:  "hack",
 	Usage: "start a hacking session for the current project",
 	Flags: append(
-		[]cli.Flag{cli.StringFlag{Name: "name", Usage: "Name to be assigned to the container"}},
+		[]cli.Flag{
+			cli.StringFlag{Name: "name", Usage: "Name to be assigned to the container"},
+			cli.BoolFlag{Name: "keep-container", Usage: "Keep the container running after the last session ends", EnvVar: "DEVSTEP_KEEP_CONTAINER"},
+		},
 		dockerRunFlags...,
 	),
 	BashComplete: func(c *cli.Context) {
@@ -18,10 +21,12 @@ var HackCmd = cli.Command{
 		args := c.Args()
 		if len(args) == 0 {
 			fmt.Println("--name")
+			fmt.Println("--keep-container")
 		}
 	},
 	Action: func(c *cli.Context) {
 		runOpts := parseRunOpts(c)
+		project.Config().KeepHackContainer = c.Bool("keep-container")
 		err := project.Hack(client, runOpts)
 		if err != nil {
 			fmt.Println(err)
@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var ImportCmd = cli.Command{
+	Name:  "import",
+	Usage: "load an environment saved with `devstep export` and use it for the current project",
+	Action: func(c *cli.Context) {
+		archivePath := c.Args().First()
+		if archivePath == "" {
+			fmt.Println("Usage: devstep import <file>")
+			os.Exit(1)
+		}
+
+		if err := project.Import(client, archivePath); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("==> Running containers need to be recreated to use the imported environment")
+	},
+}
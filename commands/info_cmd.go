@@ -16,11 +16,20 @@ var InfoCmd = cli.Command{
 
 func printConfig(config *devstep.ProjectConfig) {
 	fmt.Println("==> Project info")
+	if config.Profile != "" {
+		fmt.Printf("Profile:      %s\n", config.Profile)
+	}
 	fmt.Printf("Repository:   %s\n", config.RepositoryName)
+	if config.Registry != "" {
+		fmt.Printf("Registry:     %s\n", config.Registry)
+	}
 	fmt.Printf("Source image: %s\n", config.SourceImage)
 	fmt.Printf("Base image:   %s\n", config.BaseImage)
 	fmt.Printf("Host dir:     %s\n", config.HostDir)
 	fmt.Printf("Guest dir:    %s\n", config.GuestDir)
+	if config.CurrentDir != "" {
+		fmt.Printf("Current dir:  %s\n", config.CurrentDir)
+	}
 	fmt.Printf("Cache dir:    %s\n", config.CacheDir)
 
 	if config.Defaults != nil {
@@ -32,6 +41,45 @@ func printConfig(config *devstep.ProjectConfig) {
 		fmt.Println("\n==> Hack options:")
 		printDockerRunOpts(config.HackOpts, "")
 	}
+
+	if config.BuildOpts != nil {
+		fmt.Println("\n==> Build options:")
+		printDockerRunOpts(config.BuildOpts, "")
+	}
+
+	if config.BootstrapOpts != nil {
+		fmt.Println("\n==> Bootstrap options:")
+		printDockerRunOpts(config.BootstrapOpts, "")
+	}
+
+	if config.RunOpts != nil {
+		fmt.Println("\n==> Run options:")
+		printDockerRunOpts(config.RunOpts, "")
+	}
+
+	if config.ExecOpts != nil {
+		fmt.Println("\n==> Exec options:")
+		fmt.Printf("Env:        %v\n", config.ExecOpts.Env)
+	}
+
+	if len(config.Services) > 0 {
+		fmt.Println("\n==> Services:")
+		for _, service := range config.Services {
+			fmt.Printf("%s (container: %s, image: %s)\n", service.Alias, service.Name, service.Image)
+		}
+	}
+
+	if !config.Retention.Empty() {
+		fmt.Println("\n==> Retention policy:")
+		fmt.Println(config.Retention)
+	}
+
+	if len(config.Provision) > 0 {
+		fmt.Println("\n==> Provisioning steps:")
+		for _, step := range config.Provision {
+			fmt.Printf("%v\n", step)
+		}
+	}
 }
 
 func printDockerRunOpts(opts *devstep.DockerRunOpts, prefix string) {
@@ -40,7 +88,13 @@ func printDockerRunOpts(opts *devstep.DockerRunOpts, prefix string) {
 		privileged = *opts.Privileged
 	}
 	fmt.Printf("%sPrivileged: %v\n", prefix, privileged)
+	if opts.Workdir != "" {
+		fmt.Printf("%sWorkdir:    %s\n", prefix, opts.Workdir)
+	}
 	fmt.Printf("%sLinks:      %v\n", prefix, opts.Links)
 	fmt.Printf("%sVolumes:    %v\n", prefix, opts.Volumes)
+	if len(opts.Publish) > 0 {
+		fmt.Printf("%sPorts:      %v\n", prefix, opts.Publish)
+	}
 	fmt.Printf("%sEnv:        %v\n", prefix, opts.Env)
 }
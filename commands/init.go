@@ -5,18 +5,27 @@ import (
 	"github.com/fgrehm/devstep-cli/devstep"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 var (
 	client  devstep.DockerClient
 	project devstep.Project
+	profile string
 )
 
-func InitDevstepEnv() {
-	client = devstep.NewClient()
+func InitDevstepEnv(profileName string) {
+	InitDevstepClient(profileName)
 	reloadProject()
 }
 
+// Sets things up without loading the project, used by commands that take
+// care of loading the configuration by themselves
+func InitDevstepClient(profileName string) {
+	client = devstep.NewClient()
+	profile = profileName
+}
+
 func reloadProject() {
 	project = newProject()
 }
@@ -27,21 +36,41 @@ func newProject() devstep.Project {
 	return proj
 }
 
-func loadConfig() *devstep.ProjectConfig {
-	projectRoot, err := os.Getwd()
+func newConfigLoader() devstep.ConfigLoader {
+	projectRoot, _ := projectDirs()
+	return devstep.NewConfigLoader(client, os.Getenv("HOME"), projectRoot, profile)
+}
+
+// The root of the project devstep was run from along with the current dir
+// relative to it
+func projectDirs() (string, string) {
+	currentDir, err := os.Getwd()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	homeDir := os.Getenv("HOME")
-	loader := devstep.NewConfigLoader(client, homeDir, projectRoot)
+	projectRoot := devstep.FindProjectRoot(currentDir, os.Getenv("HOME"))
+	relativeDir, err := filepath.Rel(projectRoot, currentDir)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if relativeDir == "." {
+		relativeDir = ""
+	}
+
+	return projectRoot, relativeDir
+}
 
-	config, err := loader.Load()
+func loadConfig() *devstep.ProjectConfig {
+	homeDir := os.Getenv("HOME")
+	config, err := newConfigLoader().Load()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	_, config.CurrentDir = projectDirs()
 
 	pluginsToLoad, err := filepath.Glob(homeDir + "/devstep/plugins/*/plugin.js")
 	if err != nil {
@@ -57,9 +86,37 @@ func loadConfig() *devstep.ProjectConfig {
 		runtime.Trigger("configLoaded")
 	}
 
-	if devstep.LogLevel != "" {
+	// The log level always has a value, it only gets passed on to containers
+	// when it was set explicitly
+	if source := globalFlagSource("DEVSTEP_LOG", "log-level", "l"); source.Type != devstep.ConfigSourceDefault {
 		config.Defaults.Env["DEVSTEP_LOG"] = devstep.LogLevel
+		config.SetSource("environment.DEVSTEP_LOG", source)
+	}
+	if config.Profile != "" {
+		config.Defaults.Env["DEVSTEP_PROFILE"] = config.Profile
+		source := globalFlagSource("DEVSTEP_PROFILE", "profile")
+		config.SetSource("profile", source)
+		config.SetSource("environment.DEVSTEP_PROFILE", source)
 	}
 
 	return config
 }
+
+// Global flags can also be set from environment variables, the flag takes
+// precedence when both are provided. Values that were not set at all come
+// from the defaults.
+func globalFlagSource(envVar string, names ...string) *devstep.ConfigSource {
+	for _, arg := range os.Args[1:] {
+		for _, name := range names {
+			for _, prefix := range []string{"-", "--"} {
+				if arg == prefix+name || strings.HasPrefix(arg, prefix+name+"=") {
+					return &devstep.ConfigSource{Type: devstep.ConfigSourceFlag, Flag: names[0]}
+				}
+			}
+		}
+	}
+	if os.Getenv(envVar) != "" {
+		return &devstep.ConfigSource{Type: devstep.ConfigSourceEnv, EnvVar: envVar}
+	}
+	return &devstep.ConfigSource{Type: devstep.ConfigSourceDefault}
+}
@@ -36,6 +36,13 @@ var sampleConfig = `# The Docker repository to keep images built by devstep
 # DEFAULT: 'devstep/<CURRENT_DIR_NAME>'
 # repository: 'repo/name'
 
+# The registry 'devstep push' and 'devstep pull' share images through,
+# credentials are read from the Docker config file ('docker login'). When set,
+# 'devstep hack' pulls the environment from the registry if the project does
+# not have an image yet.
+# DEFAULT: <the Docker Hub>
+# registry: 'registry.example.com:5000'
+
 # The image used by devstep when building environments from scratch
 # DEFAULT: 'fgrehm/devstep:v1.0.0'
 # source_image: 'custom/image:tag'
@@ -52,22 +59,118 @@ var sampleConfig = `# The Docker repository to keep images built by devstep
 # Link to other existing containers (like a database for example).
 # Please note that devstep won't start the associated containers automatically
 # and an error will be raised in case the linked container does not exist or
-# if it is not running.
+# if it is not running. Use 'services' for containers that should be managed
+# by devstep.
 # DEFAULT: <empty>
 # links:
 # - "postgres:db"
 # - "memcached:mc"
 
-# Additional Docker volumes to share with the container.
+# Additional Docker volumes to share with the container. Relative host paths
+# are resolved from the directory of this file, volumes can be mounted
+# read-only with ':ro' and named volumes are supported as well. Prefix
+# relative paths with './' as bare names (like 'vendor:/vendor') are used
+# as named volumes unless a path by that name exists next to this file.
 # DEFAULT: <empty>
 # volumes:
 # - "/path/on/host:/path/on/guest"
+# - "./relative/path:/path/on/guest:ro"
+# - "volume-name:/path/on/guest"
+
+# Ports published to the host. Host IPs, UDP ports and ranges are supported
+# and ports without a host port get bound to a random one.
+# DEFAULT: <empty>
+# ports:
+# - "3000:3000"
+# - "127.0.0.1:5432:5432"
+# - "5353:53/udp"
+# - "8000-8010:8000-8010"
+# - "9000"
 
 # Environment variables.
 # DEFAULT: <empty>
 # environment:
 #   RAILS_ENV: "development"
 
+# Command specific settings that are applied on top of the ones above, they
+# accept 'privileged', 'working_dir', 'links', 'volumes', 'ports' and
+# 'environment'.
+# Please note that only 'environment' is supported for 'exec' since the
+# command runs on a container that is already running.
+# DEFAULT: <empty>
+# build:
+#   privileged: true
+# hack:
+#   environment:
+#     RAILS_ENV: "development"
+# run:
+#   links:
+#   - "redis:redis"
+# bootstrap:
+#   volumes:
+#   - "/path/on/host:/path/on/guest"
+# exec:
+#   environment:
+#     TERM: "xterm"
+
+# Dependency manifests (paths or patterns relative to the project dir) that
+# 'devstep build' checks before building. Builds are skipped when they did not
+# change since the last one and start from the source image when manifests
+# are added or removed. Use 'devstep build --force' to always build and an
+# empty list to disable the check.
+# DEFAULT: Gemfile.lock, package-lock.json, yarn.lock, go.sum, composer.lock,
+#          requirements.txt, Cargo.lock and a few others
+# build:
+#   cache_keys:
+#   - "Gemfile.lock"
+#   - "vendor/*/package-lock.json"
+
+# Paths changed on build containers that are not worth an image commit,
+# containers that only changed these paths are not commited. Patterns follow
+# the shell rules and patterns ending with '/**' match everything below a
+# dir. Use 'devstep diff' to review the changes of a build container.
+# DEFAULT: '/home', '/home/devstep' and '/home/devstep/.rnd'
+# ignore_changes:
+# - "/home"
+# - "/home/devstep"
+# - "/home/devstep/.rnd"
+# - "/tmp/**"
+
+# Containers the project depends on. They get started (or reused if they
+# already exist) and linked using the service name as the alias before
+# devstep creates containers for the project. Use 'devstep services' to manage
+# them manually.
+# DEFAULT: <empty>
+# services:
+#   db:
+#     image: 'postgres:9.4'
+#     name: 'my-project-db' # DEFAULT: '<CURRENT_DIR_NAME>-<SERVICE_NAME>'
+#     environment:
+#       POSTGRES_PASSWORD: 'secret'
+#     volumes:
+#       - '/path/on/host:/var/lib/postgresql/data'
+#     ports:
+#       - '5432:5432'
+
+# How many of the timestamped images commited on each build are kept around,
+# images are removed after every commit unless they match one of the rules
+# and the image 'latest' points to is always kept. Use 'devstep prune' to
+# remove old images manually.
+# DEFAULT: <keep everything>
+# retention:
+#   keep_last: 5
+#   keep_newer_than: '7d'
+
+# Named sets of settings that can be applied on top of the ones above by
+# running devstep with '--profile <name>' or by setting DEVSTEP_PROFILE.
+# Profiles accept the same settings as this file.
+# DEFAULT: <empty>
+# profiles:
+#   ci:
+#     privileged: true
+#     environment:
+#       RAILS_ENV: "test"
+
 # Custom provisioning steps that can be used when the available buildpacks are not
 # enough. Use it to configure addons or run additional commands during the build.
 # DEFAULT: <empty>
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var PruneCmd = cli.Command{
+	Name:  "prune",
+	Usage: "remove old images for the current environment based on the retention policy",
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "dry-run, n", Usage: "list the images that would be removed without removing them"},
+	},
+	BashComplete: func(c *cli.Context) {
+		args := c.Args()
+		if len(args) == 0 {
+			fmt.Println("-n")
+			fmt.Println("--dry-run")
+		}
+	},
+	Action: func(c *cli.Context) {
+		config := project.Config()
+		if config.Retention.Empty() {
+			fmt.Println("No retention policy configured, set `retention` on your devstep.yml to prune images")
+			os.Exit(1)
+		}
+
+		fmt.Printf("==> Pruning images for '%s' (%s)\n", config.RepositoryName, config.Retention)
+		pruned, err := project.Prune(client, c.Bool("dry-run"))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(pruned) == 0 {
+			fmt.Println("==> No images to prune")
+		}
+	},
+}
@@ -0,0 +1,19 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var PullCmd = cli.Command{
+	Name:  "pull",
+	Usage: "pull the environment pushed to the registry and use it for the current project",
+	Action: func(c *cli.Context) {
+		if err := project.Pull(client); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("==> Running containers need to be recreated to use the pulled environment")
+	},
+}
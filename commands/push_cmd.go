@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var PushCmd = cli.Command{
+	Name:  "push",
+	Usage: "push the current environment and its snapshots to the registry",
+	Action: func(c *cli.Context) {
+		if err := project.Push(client); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
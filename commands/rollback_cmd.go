@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var RollbackCmd = cli.Command{
+	Name:  "rollback",
+	Usage: "use a previously built image as the current environment (see `devstep snapshots`)",
+	BashComplete: func(c *cli.Context) {
+		if len(c.Args()) > 0 {
+			return
+		}
+		snapshots, err := project.Snapshots(client)
+		if err != nil {
+			return
+		}
+		for _, snapshot := range snapshots {
+			fmt.Println(snapshot.Tag)
+		}
+	},
+	Action: func(c *cli.Context) {
+		tag := c.Args().First()
+		if tag == "" {
+			fmt.Println("Usage: devstep rollback <tag>")
+			os.Exit(1)
+		}
+
+		if err := project.Rollback(client, tag); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("==> Running containers need to be recreated to use the snapshot")
+	},
+}
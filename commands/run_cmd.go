@@ -21,8 +21,6 @@ var RunCmd = cli.Command{
 		}
 	},
 	Action: func(c *cli.Context) {
-		project := newProject()
-
 		runOpts := parseRunOpts(c)
 		runOpts.Cmd = c.Args()
 
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var ServicesCmd = cli.Command{
+	Name:  "services",
+	Usage: "manage the containers for the services configured for the current project",
+	Subcommands: []cli.Command{
+		{
+			Name:  "up",
+			Usage: "start the services containers",
+			Action: func(c *cli.Context) {
+				err := project.StartServices(client)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			},
+		},
+		{
+			Name:  "down",
+			Usage: "stop the services containers",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "remove, r", Usage: "also remove the containers"},
+			},
+			Action: func(c *cli.Context) {
+				err := project.StopServices(client, c.Bool("remove"))
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			},
+		},
+		{
+			Name:  "status",
+			Usage: "show the state of the services containers",
+			Action: func(c *cli.Context) {
+				statuses, err := project.ServicesStatus(client)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+
+				if len(statuses) == 0 {
+					fmt.Println("No services configured for this project")
+					return
+				}
+
+				fmt.Printf("%-15s %-30s %-30s %s\n", "SERVICE", "CONTAINER", "IMAGE", "STATUS")
+				for _, status := range statuses {
+					state := "not created"
+					if status.Running {
+						state = "running"
+					} else if status.ContainerID != "" {
+						state = "stopped"
+					}
+					fmt.Printf("%-15s %-30s %-30s %s\n", status.Alias, status.Name, status.Image, state)
+				}
+			},
+		},
+	},
+}
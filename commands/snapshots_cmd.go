@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"os"
+)
+
+var SnapshotsCmd = cli.Command{
+	Name:  "snapshots",
+	Usage: "list the images built for the current environment",
+	Action: func(c *cli.Context) {
+		config := project.Config()
+		snapshots, err := project.Snapshots(client)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if len(snapshots) == 0 {
+			fmt.Printf("No snapshots found for '%s'\n", config.RepositoryName)
+			return
+		}
+
+		fmt.Printf("%-2s%-20s %-20s %s\n", "", "TAG", "CREATED", "SIZE")
+		for _, snapshot := range snapshots {
+			marker := ""
+			if snapshot.Latest {
+				marker = "*"
+			}
+			created := snapshot.Created.Local().Format("2006-01-02 15:04:05")
+			fmt.Printf("%-2s%-20s %-20s %s\n", marker, snapshot.Tag, created, humanSize(snapshot.Size))
+		}
+		fmt.Println("\n* current 'latest' image")
+	},
+}
+
+func humanSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
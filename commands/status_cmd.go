@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/fgrehm/devstep-cli/devstep"
+	"os"
+	"strings"
+	"time"
+)
+
+var StatusCmd = cli.Command{
+	Name:    "status",
+	Aliases: []string{"ps"},
+	Usage:   "show the containers and images created for the current environment",
+	Action: func(c *cli.Context) {
+		status, err := project.Status(client)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("==> Containers")
+		if len(status.Containers) == 0 {
+			fmt.Println("No containers found")
+		} else {
+			fmt.Printf("%-30s %-10s %-10s %-10s %-9s %s\n", "NAME", "ROLE", "STATE", "AGE", "SESSIONS", "PORTS")
+			for _, container := range status.Containers {
+				state := "stopped"
+				if container.Running {
+					state = "running"
+				}
+				fmt.Printf("%-30s %-10s %-10s %-10s %-9d %s\n",
+					container.Name,
+					container.Labels[devstep.LabelRole],
+					state,
+					humanDuration(time.Since(container.Created)),
+					container.ExecSessions,
+					formatPorts(container.Ports),
+				)
+			}
+		}
+
+		fmt.Printf("\n==> Images for '%s'\n", project.Config().RepositoryName)
+		if len(status.Snapshots) == 0 {
+			fmt.Println("No images found")
+			return
+		}
+		fmt.Printf("%-2s%-20s %-10s %s\n", "", "TAG", "AGE", "SIZE")
+		for _, snapshot := range status.Snapshots {
+			marker := ""
+			if snapshot.Latest {
+				marker = "*"
+			}
+			fmt.Printf("%-2s%-20s %-10s %s\n", marker, snapshot.Tag, humanDuration(time.Since(snapshot.Created)), humanSize(snapshot.Size))
+		}
+		fmt.Println("\n* current 'latest' image")
+	},
+}
+
+func formatPorts(ports []devstep.PortMapping) string {
+	formatted := []string{}
+	for _, port := range ports {
+		formatted = append(formatted, port.String())
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
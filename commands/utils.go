@@ -10,17 +10,20 @@ import (
 )
 
 var dockerRunFlags = []cli.Flag{
-	cli.StringFlag{Name: "v, volume", Usage: "Bind mount a volume"},
+	cli.StringSliceFlag{Name: "v, volume", Value: &cli.StringSlice{}, Usage: "Bind mount a volume (hostDir:guestDir[:ro|rw] or name:guestDir), relative host dirs are resolved from the project root"},
 	cli.StringFlag{Name: "w, working_dir", Usage: "Working directory inside the container"},
-	cli.StringSliceFlag{Name: "p, publish", Value: &cli.StringSlice{}, Usage: "Publish a container's port to the host (hostPort:containerPort)"},
+	cli.StringSliceFlag{Name: "p, publish", Value: &cli.StringSlice{}, Usage: "Publish a container's port to the host ([[ip:][hostPort]:]containerPort[/udp], ranges like 8000-8010 are supported)"},
 	cli.StringSliceFlag{Name: "link", Value: &cli.StringSlice{}, Usage: "Add link to another container (name:alias)"},
 	cli.StringSliceFlag{Name: "e, env", Value: &cli.StringSlice{}, Usage: "Set environment variables"},
 	cli.BoolFlag{Name: "privileged", Usage: "Give extended privileges to this container"},
+	cli.BoolFlag{Name: "no-tty", Usage: "Run without a pseudo terminal, streaming the container output (default when stdin is not a terminal)"},
 }
 
 func bashCompleteRunArgs(c *cli.Context) {
 	args := c.Args()
 	if len(args) == 0 {
+		fmt.Println("-v")
+		fmt.Println("--volume")
 		fmt.Println("-p")
 		fmt.Println("--publish")
 		fmt.Println("--link")
@@ -29,6 +32,7 @@ func bashCompleteRunArgs(c *cli.Context) {
 		fmt.Println("-e")
 		fmt.Println("--env")
 		fmt.Println("--privileged")
+		fmt.Println("--no-tty")
 	}
 }
 
@@ -51,6 +55,13 @@ func parseRunOpts(c *cli.Context) *devstep.DockerRunOpts {
 	// along properly
 	if workingDir := c.String("working_dir"); workingDir != "" {
 		project.Config().GuestDir = workingDir
+		project.Config().SetSource("working_dir", &devstep.ConfigSource{Type: devstep.ConfigSourceFlag, Flag: "working_dir"})
+	}
+
+	// Skip the pseudo terminal when we are not attached to one, like on CI
+	// servers or when piping the output of commands
+	if c.Bool("no-tty") || !stdinIsTerminal() {
+		project.Config().NoTTY = true
 	}
 
 	// Env vars
@@ -59,11 +70,20 @@ func parseRunOpts(c *cli.Context) *devstep.DockerRunOpts {
 		runOpts.Env[varAndValue[0]] = varAndValue[1]
 	}
 
+	// Volumes
+	for _, volume := range c.StringSlice("volume") {
+		expanded, err := devstep.ExpandVolume(volume, project.Config().HostDir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		runOpts.Volumes = append(runOpts.Volumes, expanded)
+	}
+
 	// Validate ports
-	validPort := regexp.MustCompile(`^\d+:\d+$`)
 	for _, port := range runOpts.Publish {
-		if !validPort.MatchString(port) {
-			fmt.Println("Invalid publish arg: " + port)
+		if _, err := devstep.ParsePorts(port); err != nil {
+			fmt.Println(err)
 			os.Exit(1)
 		}
 	}
@@ -77,3 +97,11 @@ func parseRunOpts(c *cli.Context) *devstep.DockerRunOpts {
 	}
 	return runOpts
 }
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
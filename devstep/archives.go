@@ -0,0 +1,167 @@
+package devstep
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Writes the `latest` image of the project to a tar archive that can be
+// loaded elsewhere with Import, labels (like the config hash) are kept
+func (p *project) Export(client DockerClient, output io.Writer) error {
+	image := p.RepositoryName + ":latest"
+	info, err := client.InspectImage(image)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return errors.New("Image '" + image + "' does not exist, run 'devstep build' first")
+	}
+	return client.SaveImage(image, output)
+}
+
+// Loads an archive created by Export and makes it the project environment by
+// tagging it as `latest` and with a timestamp, like builds do
+func (p *project) Import(client DockerClient, archivePath string) error {
+	tag, err := archivedImageTag(archivePath)
+	if err != nil {
+		return err
+	}
+
+	previousID, err := p.latestImageID(client)
+	if err != nil {
+		return err
+	}
+
+	// Tags that were around before loading the archive are left alone
+	existing, err := client.InspectImage(tag)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("==> Loading '%s' from '%s'\n", tag, archivePath)
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return errors.New("Error reading archive:\n  " + err.Error())
+	}
+	defer archive.Close()
+	if err = client.LoadImage(archive); err != nil {
+		return err
+	}
+
+	return p.adoptImage(client, tag, previousID, existing != nil)
+}
+
+// Makes an image loaded from somewhere else the project environment by
+// tagging it as `latest` and with a timestamp, like builds do, unless it is
+// the image `latest` pointed to before loading it. Tags from other
+// repositories are removed, unless they existed before loading the image.
+func (p *project) adoptImage(client DockerClient, name, previousID string, keepName bool) error {
+	image, err := client.InspectImage(name)
+	if err != nil {
+		return err
+	}
+	if image == nil {
+		return errors.New("Image '" + name + "' does not exist")
+	}
+
+	if image.ID == previousID {
+		fmt.Printf("==> '%s:latest' is up to date\n", p.RepositoryName)
+		return nil
+	}
+
+	timestamp := time.Now().Local().Format(snapshotTagFormat)
+	for _, tag := range []string{"latest", timestamp} {
+		fmt.Printf("==> Tagging image as '%s:%s'\n", p.RepositoryName, tag)
+		if err = client.TagImage(image.ID, p.RepositoryName, tag); err != nil {
+			return errors.New("Error tagging image:\n  " + err.Error())
+		}
+	}
+
+	if repository, _ := splitRepositoryTag(name); repository != p.RepositoryName && !keepName {
+		if err = client.RemoveImage(name); err != nil {
+			log.Debug("Error removing tag '%s': %s", name, err)
+		}
+	}
+
+	p.enforceRetention(client)
+	return nil
+}
+
+// The ID of the image `latest` points to, blank if there is none
+func (p *project) latestImageID(client DockerClient) (string, error) {
+	latest, err := client.InspectImage(p.RepositoryName + ":latest")
+	if err != nil || latest == nil {
+		return "", err
+	}
+	return latest.ID, nil
+}
+
+// Finds out the tag of the image stored on an archive created by `docker
+// save`, from the manifest used since Docker 1.10 or the older repositories
+// file
+func archivedImageTag(archivePath string) (string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", errors.New("Error reading archive:\n  " + err.Error())
+	}
+	defer file.Close()
+
+	tags := []string{}
+	archive := tar.NewReader(file)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", errors.New("Error reading archive '" + archivePath + "':\n  " + err.Error())
+		}
+
+		switch header.Name {
+		case "manifest.json":
+			var manifest []struct{ RepoTags []string }
+			if err = json.NewDecoder(archive).Decode(&manifest); err != nil {
+				return "", errors.New("Error reading archive manifest:\n  " + err.Error())
+			}
+			tags = []string{}
+			for _, entry := range manifest {
+				tags = append(tags, entry.RepoTags...)
+			}
+		case "repositories":
+			if len(tags) > 0 {
+				continue
+			}
+			var repositories map[string]map[string]string
+			if err = json.NewDecoder(archive).Decode(&repositories); err != nil {
+				return "", errors.New("Error reading archive repositories:\n  " + err.Error())
+			}
+			for repository, repositoryTags := range repositories {
+				for tag := range repositoryTags {
+					tags = append(tags, repository+":"+tag)
+				}
+			}
+		}
+	}
+
+	if len(tags) == 0 {
+		return "", errors.New("No tagged image found on '" + archivePath + "', expected an archive created by 'devstep export'")
+	}
+	if len(tags) > 1 {
+		sort.Strings(tags)
+		return "", errors.New("Archive '" + archivePath + "' has more than one image (" + strings.Join(tags, ", ") + ")")
+	}
+	return tags[0], nil
+}
+
+func splitRepositoryTag(repoTag string) (string, string) {
+	if i := strings.LastIndex(repoTag, ":"); i > strings.LastIndex(repoTag, "/") {
+		return repoTag[:i], repoTag[i+1:]
+	}
+	return repoTag, "latest"
+}
@@ -0,0 +1,137 @@
+package devstep_test
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_ExportAndImport(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	img := server.AddImage("devstep/teammate:latest", "devstep/teammate:20160101000000")
+	img.Config.Labels = map[string]string{devstep.LabelConfigHash: "abc"}
+	exporter, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/teammate"})
+
+	archive, _ := ioutil.TempFile("", "devstep-export-")
+	defer os.Remove(archive.Name())
+	ok(t, exporter.Export(client, archive))
+	archive.Close()
+
+	otherServer, otherClient := newTestClient()
+	defer otherServer.Close()
+	previous := otherServer.AddImage("devstep/project:latest")
+	importer, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project"})
+
+	ok(t, importer.Import(otherClient, archive.Name()))
+
+	latest := otherServer.Image("devstep/project:latest")
+	equals(t, img.ID, latest.ID)
+	equals(t, "abc", latest.Config.Labels[devstep.LabelConfigHash])
+	equals(t, 2, len(latest.RepoTags))
+	assert(t, otherServer.Image("devstep/teammate:latest") == nil, "Tag from the exported project was kept")
+	assert(t, otherServer.Image(previous.ID) != nil, "Previous image was removed")
+}
+
+func Test_ImportKeepsTagsThatAlreadyExisted(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	img := server.AddImage("devstep/teammate:latest")
+	exporter, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/teammate"})
+
+	archive, _ := ioutil.TempFile("", "devstep-export-")
+	defer os.Remove(archive.Name())
+	ok(t, exporter.Export(client, archive))
+	archive.Close()
+
+	importer, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project"})
+	captureOutput(func() {
+		ok(t, importer.Import(client, archive.Name()))
+	})
+
+	equals(t, img.ID, server.Image("devstep/project:latest").ID)
+	assert(t, server.Image("devstep/teammate:latest") != nil, "Tag that existed before the import was removed")
+}
+
+func Test_ExportWithoutImage(t *testing.T) {
+	project, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project"})
+	clientMock := NewMockClient()
+	clientMock.InspectImageFunc = func(string) (*devstep.DockerImage, error) {
+		return nil, nil
+	}
+
+	err := project.Export(clientMock, ioutil.Discard)
+	equals(t, "Image 'devstep/project:latest' does not exist, run 'devstep build' first", errString(err))
+}
+
+func Test_ImportReadsLegacyArchives(t *testing.T) {
+	archive := writeArchive(map[string]string{
+		"repositories": `{"devstep/project":{"latest":"abc"}}`,
+	})
+	defer os.Remove(archive)
+
+	project, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project"})
+	clientMock := NewMockClient()
+	loaded := false
+	clientMock.LoadImageFunc = func(io.Reader) error {
+		loaded = true
+		return nil
+	}
+	clientMock.InspectImageFunc = func(name string) (*devstep.DockerImage, error) {
+		if loaded {
+			return &devstep.DockerImage{ID: "loaded-id"}, nil
+		}
+		return &devstep.DockerImage{ID: "previous-id"}, nil
+	}
+	tagged := []string{}
+	clientMock.TagImageFunc = func(image, repository, tag string) error {
+		equals(t, "loaded-id", image)
+		tagged = append(tagged, repository+":"+tag)
+		return nil
+	}
+	clientMock.RemoveImageFunc = func(name string) error {
+		t.Fatal("Tag of the project was removed")
+		return nil
+	}
+
+	ok(t, project.Import(clientMock, archive))
+	assert(t, loaded, "Archive was not loaded")
+	equals(t, 2, len(tagged))
+	equals(t, "devstep/project:latest", tagged[0])
+}
+
+func Test_ImportRejectsUnknownArchives(t *testing.T) {
+	project, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project"})
+	clientMock := NewMockClient()
+
+	archive := writeArchive(map[string]string{"some/file": "contents"})
+	defer os.Remove(archive)
+	err := project.Import(clientMock, archive)
+	assert(t, strings.HasPrefix(errString(err), "No tagged image found on"), "Unexpected error: "+errString(err))
+
+	archive = writeArchive(map[string]string{
+		"manifest.json": `[{"RepoTags":["a:latest"]},{"RepoTags":["b:latest"]}]`,
+	})
+	defer os.Remove(archive)
+	err = project.Import(clientMock, archive)
+	equals(t, "Archive '"+archive+"' has more than one image (a:latest, b:latest)", errString(err))
+}
+
+func writeArchive(files map[string]string) string {
+	file, _ := ioutil.TempFile("", "devstep-archive-")
+	defer file.Close()
+	archive := tar.NewWriter(file)
+	for name, contents := range files {
+		archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		archive.Write([]byte(contents))
+	}
+	archive.Close()
+	return file.Name()
+}
@@ -0,0 +1,159 @@
+package devstep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dependency manifests that decide whether a build can be skipped, used when
+// `build.cache_keys` is not configured
+var DefaultCacheKeys = []string{
+	"Gemfile.lock",
+	"package-lock.json",
+	"yarn.lock",
+	"npm-shrinkwrap.json",
+	"go.sum",
+	"Godeps/Godeps.json",
+	"glide.lock",
+	"composer.lock",
+	"requirements.txt",
+	"Pipfile.lock",
+	"Cargo.lock",
+	"mix.lock",
+}
+
+// The state of the dependency manifests of a project at the time of a build,
+// it gets stored on the image labels when the build is commited
+type buildCache struct {
+	Key       string            // hash of the manifests and of the configuration in use
+	Manifests map[string]string // hash of each manifest, keyed by its path relative to the host dir
+}
+
+func (p *project) cacheKeys() []string {
+	if p.CacheKeys == nil {
+		return DefaultCacheKeys
+	}
+	return p.CacheKeys
+}
+
+// Hashes the dependency manifests found on the host dir, returns nil when
+// there are none as there is nothing to compare builds with
+func (p *project) dependencyState() (*buildCache, error) {
+	if p.HostDir == "" {
+		return nil, nil
+	}
+
+	cache := &buildCache{Manifests: make(map[string]string)}
+	for _, pattern := range p.cacheKeys() {
+		matches, err := filepath.Glob(filepath.Join(p.HostDir, pattern))
+		if err != nil {
+			return nil, errors.New("Invalid cache key '" + pattern + "'")
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			data, err := ioutil.ReadFile(match)
+			if err != nil {
+				return nil, errors.New("Error reading dependency manifest:\n  " + err.Error())
+			}
+			relative, _ := filepath.Rel(p.HostDir, match)
+			sum := sha256.Sum256(data)
+			cache.Manifests[filepath.ToSlash(relative)] = hex.EncodeToString(sum[:])
+		}
+	}
+	if len(cache.Manifests) == 0 {
+		return nil, nil
+	}
+
+	// Changes to the settings used by builds (like new provisioning steps)
+	// invalidate the cache as well
+	hash := sha256.New()
+	fmt.Fprintf(hash, "config=%s\n", p.buildConfigHash())
+	for _, manifest := range sortedKeys(cache.Manifests) {
+		fmt.Fprintf(hash, "%s=%s\n", manifest, cache.Manifests[manifest])
+	}
+	cache.Key = hex.EncodeToString(hash.Sum(nil))
+	return cache, nil
+}
+
+// A hash of the settings that affect what builds install, changes to the
+// other ones (like hack options or services) keep the cache
+func (p *project) buildConfigHash() string {
+	entries := []*ConfigEntry{}
+	for _, entry := range p.Explain() {
+		if buildSetting(entry.Key) && !devstepVariable(entry.Key) {
+			entries = append(entries, entry)
+		}
+	}
+	return hashEntries(entries)
+}
+
+func buildSetting(key string) bool {
+	if key == "source_image" {
+		return true
+	}
+	for _, prefix := range []string{"provision[", "build.cache_keys[", "environment.", "volumes[", "build.environment.", "build.volumes["} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads the state of the last build from the labels of the `latest` image,
+// images commited before the caching was introduced have none
+func (p *project) lastBuildCache(client DockerClient) (*buildCache, error) {
+	image, err := client.InspectImage(p.RepositoryName + ":latest")
+	if err != nil || image == nil {
+		return nil, err
+	}
+
+	key := image.Labels[LabelDependencies]
+	if key == "" {
+		return nil, nil
+	}
+	cache := &buildCache{Key: key}
+	if err = json.Unmarshal([]byte(image.Labels[LabelManifests]), &cache.Manifests); err != nil {
+		log.Debug("Invalid manifests label on '%s:latest': %s", p.RepositoryName, err)
+		cache.Manifests = nil
+	}
+	return cache, nil
+}
+
+// Manifests that got added or removed since the last build (like when
+// switching package managers) mean the dependencies installed on the image
+// can't be trusted anymore
+func (c *buildCache) changedDrastically(last *buildCache) bool {
+	if last.Manifests == nil {
+		return false
+	}
+	if len(c.Manifests) != len(last.Manifests) {
+		return true
+	}
+	for manifest := range c.Manifests {
+		if _, found := last.Manifests[manifest]; !found {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *buildCache) labels() map[string]string {
+	if c == nil {
+		return nil
+	}
+	// Map keys are sorted when encoding, so equal states get equal labels
+	encoded, _ := json.Marshal(c.Manifests)
+	return map[string]string{
+		LabelDependencies: c.Key,
+		LabelManifests:    string(encoded),
+	}
+}
@@ -0,0 +1,315 @@
+package devstep_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_BuildSkipsWhenDependenciesDidNotChange(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	defer os.RemoveAll(tempDir)
+	writeFile(tempDir+"/Gemfile.lock", "rails (4.2.0)")
+
+	config := &devstep.ProjectConfig{
+		SourceImage:    "source/image:tag",
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        tempDir,
+	}
+	project, err := devstep.NewProject(config)
+	ok(t, err)
+
+	runs := []*devstep.DockerRunOpts{}
+	latestLabels := map[string]string{}
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(opts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runs = append(runs, opts)
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.CommitFunc = func(opts *devstep.DockerCommitOpts) error {
+		if opts.Tag == "latest" {
+			latestLabels = opts.Labels
+		}
+		return nil
+	}
+	clientMock.InspectImageFunc = func(string) (*devstep.DockerImage, error) {
+		return &devstep.DockerImage{ID: "latest-id", Labels: latestLabels}, nil
+	}
+
+	// Images without the labels get built
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 1, len(runs))
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 1, len(runs))
+
+	config.ForceBuild = true
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 2, len(runs))
+	config.ForceBuild = false
+
+	writeFile(tempDir+"/Gemfile.lock", "rails (5.0.0)")
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 3, len(runs))
+	equals(t, "repo/name:latest", runs[2].Image)
+
+	// Configuration changes invalidate the cache
+	config.Provision = [][]string{{"make"}}
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 4, len(runs))
+}
+
+func Test_BuildStartsFromSourceImageWhenManifestsAreAddedOrRemoved(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	defer os.RemoveAll(tempDir)
+	writeFile(tempDir+"/Gemfile.lock", "rails (4.2.0)")
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage:    "source/image:tag",
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        tempDir,
+	})
+	ok(t, err)
+
+	runs := []*devstep.DockerRunOpts{}
+	latestLabels := map[string]string{}
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(opts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runs = append(runs, opts)
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.CommitFunc = func(opts *devstep.DockerCommitOpts) error {
+		if opts.Tag == "latest" {
+			latestLabels = opts.Labels
+		}
+		return nil
+	}
+	clientMock.InspectImageFunc = func(string) (*devstep.DockerImage, error) {
+		return &devstep.DockerImage{ID: "latest-id", Labels: latestLabels}, nil
+	}
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+
+	writeFile(tempDir+"/package-lock.json", "{}")
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 2, len(runs))
+	equals(t, "source/image:tag", runs[1].Image)
+
+	os.Remove(tempDir + "/Gemfile.lock")
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 3, len(runs))
+	equals(t, "source/image:tag", runs[2].Image)
+}
+
+func Test_BuildCommitsDependenciesHash(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	defer os.RemoveAll(tempDir)
+	os.MkdirAll(tempDir+"/web", 0755)
+	writeFile(tempDir+"/web/package-lock.json", "{}")
+	writeFile(tempDir+"/Gemfile.lock", "rails (4.2.0)")
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage:    "source/image:tag",
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        tempDir,
+		CacheKeys:      []string{"*/package-lock.json"},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(opts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	var commits []*devstep.DockerCommitOpts
+	clientMock.CommitFunc = func(opts *devstep.DockerCommitOpts) error {
+		commits = append(commits, opts)
+		return nil
+	}
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+
+	equals(t, 2, len(commits))
+	for _, commit := range commits {
+		assert(t, commit.Labels[devstep.LabelDependencies] != "", "Dependencies hash label not set")
+		equals(t, `{"web/package-lock.json":"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"}`, commit.Labels[devstep.LabelManifests])
+	}
+}
+
+func Test_BuildCacheIgnoresSettingsNotUsedByBuilds(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	defer os.RemoveAll(tempDir)
+	writeFile(tempDir+"/Gemfile.lock", "rails (4.2.0)")
+
+	newConfig := func() *devstep.ProjectConfig {
+		return &devstep.ProjectConfig{
+			SourceImage:    "source/image:tag",
+			BaseImage:      "repo/name:latest",
+			RepositoryName: "repo/name",
+			HostDir:        tempDir,
+			Defaults:       &devstep.DockerRunOpts{Env: map[string]string{"FOO": "bar"}},
+			HackOpts:       &devstep.DockerRunOpts{Env: map[string]string{}},
+			BuildOpts:      &devstep.DockerRunOpts{Env: map[string]string{}},
+		}
+	}
+
+	runs := 0
+	latestLabels := map[string]string{}
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(opts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runs++
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.CommitFunc = func(opts *devstep.DockerCommitOpts) error {
+		if opts.Tag == "latest" {
+			latestLabels = opts.Labels
+		}
+		return nil
+	}
+	clientMock.InspectImageFunc = func(string) (*devstep.DockerImage, error) {
+		return &devstep.DockerImage{ID: "latest-id", Labels: latestLabels}, nil
+	}
+
+	project, err := devstep.NewProject(newConfig())
+	ok(t, err)
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 1, runs)
+
+	config := newConfig()
+	config.Defaults.Env["DEVSTEP_LOG"] = "DEBUG"
+	config.HackOpts.Env["EDITOR"] = "vim"
+	config.Retention = &devstep.RetentionPolicy{KeepLast: 3}
+	project, err = devstep.NewProject(config)
+	ok(t, err)
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 1, runs)
+
+	config.BuildOpts.Env["RAILS_ENV"] = "test"
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 2, runs)
+}
+
+func Test_BuildRecordsDependenciesWhenNothingChanged(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	defer os.RemoveAll(tempDir)
+	writeFile(tempDir+"/Gemfile.lock", "rails (4.2.0)")
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage:    "source/image:tag",
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        tempDir,
+	})
+	ok(t, err)
+
+	runs := 0
+	commited := []string{}
+	latestLabels := map[string]string{}
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(opts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runs++
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.ContainerChangesFunc = func(string) ([]*devstep.DockerChange, error) {
+		return []*devstep.DockerChange{}, nil
+	}
+	clientMock.CommitFunc = func(opts *devstep.DockerCommitOpts) error {
+		commited = append(commited, opts.Tag)
+		latestLabels = opts.Labels
+		return nil
+	}
+	clientMock.InspectImageFunc = func(string) (*devstep.DockerImage, error) {
+		return &devstep.DockerImage{ID: "latest-id", Labels: latestLabels}, nil
+	}
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, []string{"latest"}, commited)
+	assert(t, latestLabels[devstep.LabelDependencies] != "", "Dependencies hash label not set")
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 1, runs)
+}
+
+func Test_BuildWithoutManifestsIsNotCached(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	defer os.RemoveAll(tempDir)
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage:    "source/image:tag",
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        tempDir,
+	})
+	ok(t, err)
+
+	runs := 0
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(opts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runs++
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+
+	equals(t, 2, runs)
+}
+
+func Test_LoadsCacheKeys(t *testing.T) {
+	tempHomeDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempHomeDir+"/devstep.yml", `
+build:
+  cache_keys:
+  - 'Gemfile.lock'
+`)
+	defer os.RemoveAll(tempHomeDir)
+	tempProjectDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempProjectDir+"/devstep.yml", `
+build:
+  cache_keys:
+  - 'go.sum'
+  - 'web/*.lock'
+`)
+	defer os.RemoveAll(tempProjectDir)
+
+	loader, _ := newConfigLoader(tempHomeDir, tempProjectDir)
+	config, err := loader.Load()
+	ok(t, err)
+
+	equals(t, []string{"go.sum", "web/*.lock"}, config.CacheKeys)
+	equals(t, tempProjectDir+"/devstep.yml:5", config.Source("build.cache_keys[1]").String())
+}
+
+func Test_ReportsInvalidCacheKeys(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+cache_keys:
+- 'Gemfile.lock'
+build:
+  cache_keys:
+  - '/abs/go.sum'
+  - 'web/[.lock'
+hack:
+  cache_keys: []
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	_, err := loader.Load()
+
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, 4, len(errs))
+	equals(t, 2, errs[0].Line)
+	equals(t, "'cache_keys' can only be set inside the build block", errs[0].Message)
+	equals(t, 6, errs[1].Line)
+	equals(t, "Invalid cache key '/abs/go.sum', expected a path or pattern relative to the project dir", errs[1].Message)
+	equals(t, 7, errs[2].Line)
+	equals(t, "Invalid cache key 'web/[.lock', expected a path or pattern relative to the project dir", errs[2].Message)
+	equals(t, 9, errs[3].Line)
+	equals(t, "Unknown key 'cache_keys'", errs[3].Message)
+}
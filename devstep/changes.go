@@ -0,0 +1,110 @@
+package devstep
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Paths that get touched on every container started from the devstep images,
+// used when `ignore_changes` is not configured
+var DefaultIgnoredChanges = []string{
+	"/home",
+	"/home/devstep",
+	"/home/devstep/.rnd",
+}
+
+// The changes made to the filesystem of a container, see Project.Diff
+type ContainerDiff struct {
+	ContainerID string          `json:"container"`
+	Changes     []*DockerChange `json:"changes"` // changes that are not ignored, sorted by path
+	Ignored     int             `json:"ignored"` // number of changes matched by the ignore list
+}
+
+func (p *project) ignoredChanges() []string {
+	if p.IgnoreChanges == nil {
+		return DefaultIgnoredChanges
+	}
+	return p.IgnoreChanges
+}
+
+// Lists the changes made to a container that are not ignored
+func (p *project) containerDiff(client DockerClient, containerID string) (*ContainerDiff, error) {
+	changes, err := client.ContainerChanges(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ContainerDiff{ContainerID: containerID, Changes: []*DockerChange{}}
+	for _, change := range changes {
+		if changeIgnored(p.ignoredChanges(), change.Path) {
+			diff.Ignored++
+		} else {
+			diff.Changes = append(diff.Changes, change)
+		}
+	}
+	sort.Sort(changesByPath(diff.Changes))
+	return diff, nil
+}
+
+// Shows the changes made to a container, defaulting to the last build
+// container of the project that is still around
+func (p *project) Diff(client DockerClient, containerName string) (*ContainerDiff, error) {
+	if containerName == "" {
+		container, err := p.lastBuildContainer(client)
+		if err != nil {
+			return nil, err
+		}
+		if container == nil {
+			return nil, errors.New("No build container found, use 'devstep build --no-commit' to keep it around")
+		}
+		return p.containerDiff(client, container.ID)
+	}
+
+	containerID, err := client.LookupContainerID(containerName)
+	if err != nil {
+		return nil, err
+	}
+	return p.containerDiff(client, containerID)
+}
+
+func (p *project) lastBuildContainer(client DockerClient) (*DockerContainer, error) {
+	var last *DockerContainer
+	for _, role := range []string{RoleBuild, RoleBootstrap} {
+		containers, err := client.FindContainers(p.roleFilter(role))
+		if err != nil {
+			return nil, err
+		}
+		for _, container := range containers {
+			if last == nil || container.Created.After(last.Created) {
+				last = container
+			}
+		}
+	}
+	return last, nil
+}
+
+// Patterns follow the rules of path.Match, patterns ending with `/**` match
+// the dir and everything below it
+func changeIgnored(patterns []string, changedPath string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/**") {
+			dir := strings.TrimSuffix(pattern, "/**")
+			for current := changedPath; current != "/" && current != "."; current = path.Dir(current) {
+				if matched, _ := path.Match(dir, current); matched {
+					return true
+				}
+			}
+		} else if matched, _ := path.Match(pattern, changedPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+type changesByPath []*DockerChange
+
+func (c changesByPath) Len() int           { return len(c) }
+func (c changesByPath) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c changesByPath) Less(i, j int) bool { return c[i].Path < c[j].Path }
@@ -0,0 +1,211 @@
+package devstep_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_DiffIgnoresDefaultChangesInAnyOrder(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.LookupContainerIDFunc = func(name string) (string, error) {
+		return name + "-id", nil
+	}
+	clientMock.ContainerChangesFunc = func(string) ([]*devstep.DockerChange, error) {
+		return []*devstep.DockerChange{
+			{Path: "/home/devstep/.rnd", Kind: devstep.ChangeModified},
+			{Path: "/home", Kind: devstep.ChangeModified},
+			{Path: "/home/devstep", Kind: devstep.ChangeModified},
+		}, nil
+	}
+
+	diff, err := project.Diff(clientMock, "container")
+	ok(t, err)
+	equals(t, "container-id", diff.ContainerID)
+	equals(t, []*devstep.DockerChange{}, diff.Changes)
+	equals(t, 3, diff.Ignored)
+}
+
+func Test_DiffUsesConfiguredPatterns(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+		IgnoreChanges:  []string{"/home/devstep", "/tmp/**", "/var/log/*.log"},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.LookupContainerIDFunc = func(name string) (string, error) {
+		return name + "-id", nil
+	}
+	clientMock.ContainerChangesFunc = func(string) ([]*devstep.DockerChange, error) {
+		return []*devstep.DockerChange{
+			{Path: "/tmp", Kind: devstep.ChangeModified},
+			{Path: "/tmp/cache/file", Kind: devstep.ChangeAdded},
+			{Path: "/var/log/build.log", Kind: devstep.ChangeAdded},
+			{Path: "/var/log/nested/build.log", Kind: devstep.ChangeAdded},
+			{Path: "/home/devstep/.rnd", Kind: devstep.ChangeModified},
+			{Path: "/home/devstep", Kind: devstep.ChangeModified},
+			{Path: "/home", Kind: devstep.ChangeModified},
+		}, nil
+	}
+
+	diff, err := project.Diff(clientMock, "container")
+	ok(t, err)
+	equals(t, []*devstep.DockerChange{
+		{Path: "/home", Kind: devstep.ChangeModified},
+		{Path: "/home/devstep/.rnd", Kind: devstep.ChangeModified},
+		{Path: "/var/log/nested/build.log", Kind: devstep.ChangeAdded},
+	}, diff.Changes)
+	equals(t, 4, diff.Ignored)
+}
+
+func Test_DiffDefaultsToTheLastBuildContainer(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	now := time.Now()
+	clientMock := NewMockClient()
+	clientMock.FindContainersFunc = func(labels map[string]string) ([]*devstep.DockerContainer, error) {
+		equals(t, "/path/on/host", labels[devstep.LabelProject])
+		if labels[devstep.LabelRole] == devstep.RoleBuild {
+			return []*devstep.DockerContainer{
+				{ID: "old-build", Created: now.Add(-time.Hour)},
+				{ID: "new-build", Created: now},
+			}, nil
+		}
+		return []*devstep.DockerContainer{{ID: "bootstrap", Created: now.Add(-time.Minute)}}, nil
+	}
+
+	diff, err := project.Diff(clientMock, "")
+	ok(t, err)
+	equals(t, "new-build", diff.ContainerID)
+
+	clientMock.FindContainersFunc = func(map[string]string) ([]*devstep.DockerContainer, error) {
+		return []*devstep.DockerContainer{}, nil
+	}
+	_, err = project.Diff(clientMock, "")
+	equals(t, "No build container found, use 'devstep build --no-commit' to keep it around", errString(err))
+}
+
+func Test_BuildSkipsCommitWhenOnlyIgnoredPathsChanged(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(*devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.ContainerChangesFunc = func(string) ([]*devstep.DockerChange, error) {
+		return []*devstep.DockerChange{
+			{Path: "/home/devstep", Kind: devstep.ChangeModified},
+			{Path: "/home", Kind: devstep.ChangeModified},
+		}, nil
+	}
+	commits := 0
+	clientMock.CommitFunc = func(*devstep.DockerCommitOpts) error {
+		commits++
+		return nil
+	}
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	equals(t, 0, commits)
+}
+
+func Test_BuildReportsErrorsListingChanges(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(*devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.ContainerChangesFunc = func(string) ([]*devstep.DockerChange, error) {
+		return nil, errors.New("boom")
+	}
+	removed := ""
+	clientMock.RemoveContainerFunc = func(id string) error {
+		removed = id
+		return nil
+	}
+
+	err = project.Build(clientMock, &devstep.DockerRunOpts{})
+	equals(t, "boom", errString(err))
+	equals(t, "cid", removed)
+}
+
+func Test_BuildWithoutCommit(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+		NoCommit:       true,
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(*devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.CommitFunc = func(*devstep.DockerCommitOpts) error {
+		t.Fatal("Container was commited")
+		return nil
+	}
+	clientMock.RemoveContainerFunc = func(string) error {
+		t.Fatal("Container was removed")
+		return nil
+	}
+
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+}
+
+func Test_LoadsIgnoredChanges(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+ignore_changes:
+- '/home/**'
+- 'tmp/*'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	_, err := loader.Load()
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, 1, len(errs))
+	equals(t, 4, errs[0].Line)
+	equals(t, "Invalid path 'tmp/*', expected an absolute path or pattern like '/tmp/**'", errs[0].Message)
+
+	writeFile(tempDir+"/devstep.yml", `
+ignore_changes:
+- '/home/**'
+`)
+	config, err := loader.Load()
+	ok(t, err)
+	equals(t, []string{"/home/**"}, config.IgnoreChanges)
+	equals(t, tempDir+"/devstep.yml:3", config.Source("ignore_changes[0]").String())
+}
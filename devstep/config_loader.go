@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"errors"
 	"gopkg.in/yaml.v1"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -13,31 +17,65 @@ import (
 
 type ConfigLoader interface {
 	Load() (*ProjectConfig, error)
+	Validate() error
 }
 
 type configLoader struct {
 	client        DockerClient
 	homeDirectory string
 	projectRoot   string
+	profile       string
 }
 
 type yamlConfig struct {
-	RepositoryName *string           `yaml:"repository"`
-	SourceImage    *string           `yaml:"source_image"`
-	CacheDir       *string           `yaml:"cache_dir"`
-	GuestDir       *string           `yaml:"working_dir"`
-	Privileged     *bool             `yaml:"privileged"`
-	Links          []string          `yaml:"links"`
-	Volumes        []string          `yaml:"volumes"`
-	Env            map[string]string `yaml:"environment"`
-	Hack           *yamlConfig       `yaml:"hack"`
-}
-
-func NewConfigLoader(client DockerClient, homeDirectory, projectRoot string) ConfigLoader {
+	RepositoryName *string                 `yaml:"repository"`
+	SourceImage    *string                 `yaml:"source_image"`
+	Registry       *string                 `yaml:"registry"`
+	CacheDir       *string                 `yaml:"cache_dir"`
+	GuestDir       *string                 `yaml:"working_dir"`
+	Privileged     *bool                   `yaml:"privileged"`
+	Links          []string                `yaml:"links"`
+	Volumes        []string                `yaml:"volumes"`
+	Ports          []string                `yaml:"ports"`
+	Env            map[string]string       `yaml:"environment"`
+	Provision      [][]string              `yaml:"provision"`
+	Services       map[string]*yamlService `yaml:"services"`
+	Retention      *yamlRetention          `yaml:"retention"`
+	CacheKeys      []string                `yaml:"cache_keys"`
+	IgnoreChanges  []string                `yaml:"ignore_changes"`
+	Hack           *yamlConfig             `yaml:"hack"`
+	Build          *yamlConfig             `yaml:"build"`
+	Bootstrap      *yamlConfig             `yaml:"bootstrap"`
+	Run            *yamlConfig             `yaml:"run"`
+	Exec           *yamlConfig             `yaml:"exec"`
+	Profiles       map[string]*yamlConfig  `yaml:"profiles"`
+
+	file  string         // the file the config was parsed from
+	lines map[string]int // the lines where each key was defined
+	path  []string       // the path to this config in the file, like `hack`
+}
+
+type yamlService struct {
+	Name    *string           `yaml:"name"`
+	Image   *string           `yaml:"image"`
+	Env     map[string]string `yaml:"environment"`
+	Volumes []string          `yaml:"volumes"`
+	Ports   []string          `yaml:"ports"`
+}
+
+type yamlRetention struct {
+	KeepLast      *int    `yaml:"keep_last"`
+	KeepNewerThan *string `yaml:"keep_newer_than"`
+}
+
+// Creates a loader for the project config, the profile is optional and when
+// provided its settings get applied on top of the ones from the config files
+func NewConfigLoader(client DockerClient, homeDirectory, projectRoot, profile string) ConfigLoader {
 	return &configLoader{
 		client:        client,
 		homeDirectory: homeDirectory,
 		projectRoot:   projectRoot,
+		profile:       profile,
 	}
 }
 
@@ -49,34 +87,32 @@ func (l *configLoader) Load() (*ProjectConfig, error) {
 		return nil, err
 	}
 
-	yamlConf, err := parseYaml(l.homeDirectory + "/devstep.yml")
+	homeConf, projectConf, err := l.parseConfigFiles()
 	if err != nil {
 		return nil, err
 	}
-	if yamlConf != nil {
+
+	if homeConf != nil {
 		log.Info("Loaded config from home dir")
-		log.Debug("Home dir config: %+v", yamlConf)
-		if yamlConf.RepositoryName != nil {
-			return nil, errors.New("Repository name can't be set globally")
-		}
-		if yamlConf.Privileged != nil {
-			return nil, errors.New("Privileged name can't be set globally")
-		}
-		assignYamlValues(yamlConf, config)
-	}
-	yamlConf, err = parseYaml(l.projectRoot + "/devstep.yml")
-	if err != nil {
-		return nil, err
+		log.Debug("Home dir config: %+v", homeConf)
+		assignYamlValues(homeConf, config)
 	}
-	if yamlConf != nil {
+	if projectConf != nil {
 		log.Info("Loaded config from project dir")
-		log.Debug("Project dir config: %+v", yamlConf)
-		assignYamlValues(yamlConf, config)
-		if yamlConf.Privileged != nil {
-			config.Defaults.Privileged = yamlConf.Privileged
+		log.Debug("Project dir config: %+v", projectConf)
+		assignProjectYamlValues(projectConf, config)
+	}
+
+	if l.profile != "" {
+		if err = l.applyProfile(config, homeConf, projectConf); err != nil {
+			return nil, err
 		}
 	}
 
+	if err = l.finalizeServices(config); err != nil {
+		return nil, err
+	}
+
 	tags, err := l.client.ListTags(config.RepositoryName)
 	if err != nil {
 		return nil, err
@@ -93,6 +129,47 @@ func (l *configLoader) Load() (*ProjectConfig, error) {
 	return config, nil
 }
 
+// Checks the config files for problems without talking to the Docker daemon
+func (l *configLoader) Validate() error {
+	homeConf, projectConf, err := l.parseConfigFiles()
+	if err != nil {
+		return err
+	}
+	if l.profile != "" {
+		_, inHome := lookupProfile(homeConf, l.profile)
+		_, inProject := lookupProfile(projectConf, l.profile)
+		if !inHome && !inProject {
+			return errors.New("Profile '" + l.profile + "' is not defined")
+		}
+	}
+	return nil
+}
+
+// Parses the home and project dir config files, reporting the problems
+// found on both of them at once
+func (l *configLoader) parseConfigFiles() (*yamlConfig, *yamlConfig, error) {
+	errs := ConfigErrors{}
+
+	homeConf, err := parseYaml(l.homeDirectory+"/devstep.yml", true)
+	if configErrs, ok := err.(ConfigErrors); ok {
+		errs = append(errs, configErrs...)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	projectConf, err := parseYaml(l.projectRoot+"/devstep.yml", false)
+	if configErrs, ok := err.(ConfigErrors); ok {
+		errs = append(errs, configErrs...)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return homeConf, projectConf, nil
+}
+
 func (l *configLoader) buildDefaultConfig() (*ProjectConfig, error) {
 	projectDirName := filepath.Base(l.projectRoot)
 	repositoryName := "devstep/" + projectDirName
@@ -110,28 +187,86 @@ func (l *configLoader) buildDefaultConfig() (*ProjectConfig, error) {
 			Env:      map[string]string{"DEVSTEP_CONTAINER_NAME": (projectDirName + "-" + suffix)},
 			Hostname: projectDirName,
 		},
-		HackOpts: &DockerRunOpts{Env: make(map[string]string)},
+		HackOpts:      &DockerRunOpts{Env: make(map[string]string)},
+		BuildOpts:     &DockerRunOpts{Env: make(map[string]string)},
+		BootstrapOpts: &DockerRunOpts{Env: make(map[string]string)},
+		RunOpts:       &DockerRunOpts{Env: make(map[string]string)},
+		ExecOpts:      &DockerRunOpts{Env: make(map[string]string)},
 	}
 
 	return config, nil
 }
 
-func parseYaml(configPath string) (*yamlConfig, error) {
+// Profiles defined on the home dir are applied before the ones defined on the
+// project dir, and both take precedence over the regular configs
+func (l *configLoader) applyProfile(config *ProjectConfig, homeConf, projectConf *yamlConfig) error {
+	log.Info("Applying '%s' profile", l.profile)
+
+	homeProfile, inHome := lookupProfile(homeConf, l.profile)
+	projectProfile, inProject := lookupProfile(projectConf, l.profile)
+	if !inHome && !inProject {
+		return errors.New("Profile '" + l.profile + "' is not defined")
+	}
+
+	if homeProfile != nil {
+		assignYamlValues(homeProfile, config)
+	}
+	if projectProfile != nil {
+		assignProjectYamlValues(projectProfile, config)
+	}
+
+	config.Profile = l.profile
+	return nil
+}
+
+func lookupProfile(yamlConf *yamlConfig, name string) (*yamlConfig, bool) {
+	if yamlConf == nil || yamlConf.Profiles == nil {
+		return nil, false
+	}
+	profile, found := yamlConf.Profiles[name]
+	return profile, found
+}
+
+// Sorts services by alias so that they are always started in the same order,
+// assigns default container names and makes sure they have an image
+func (l *configLoader) finalizeServices(config *ProjectConfig) error {
+	sort.Sort(servicesByAlias(config.Services))
+
+	projectDirName := filepath.Base(l.projectRoot)
+	for _, service := range config.Services {
+		if service.Name == "" {
+			service.Name = projectDirName + "-" + service.Alias
+		}
+		if service.Image == "" {
+			return errors.New("No image configured for the '" + service.Alias + "' service")
+		}
+	}
+	return nil
+}
+
+type servicesByAlias []*ServiceConfig
+
+func (s servicesByAlias) Len() int           { return len(s) }
+func (s servicesByAlias) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s servicesByAlias) Less(i, j int) bool { return s[i].Alias < s[j].Alias }
+
+var templateErrorLine = regexp.MustCompile(`^template: config:(\d+)(?::\d+)?: (.*)$`)
+var yamlErrorLine = regexp.MustCompile(`^(?:YAML error|yaml): line (\d+): (.*)$`)
+
+// Parses a config file, global configs are the ones from the home dir and
+// some settings can't be set on them. Problems with the file contents are
+// reported as ConfigErrors.
+func parseYaml(configPath string, global bool) (*yamlConfig, error) {
 	configInfo, err := os.Stat(configPath)
 	// File does not exist or is a directory
 	if err != nil || configInfo.IsDir() {
 		return nil, nil
 	}
 
-	// Parse yaml
-	file, err := os.Open(configPath)
+	data, err := ioutil.ReadFile(configPath)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("Error reading '" + configPath + "'\n  " + err.Error())
 	}
-	defer file.Close()
-
-	data := make([]byte, configInfo.Size())
-	_, err = file.Read(data)
 
 	funcMap := template.FuncMap{
 		"env": os.Getenv,
@@ -139,68 +274,297 @@ func parseYaml(configPath string) (*yamlConfig, error) {
 
 	tmpl, err := template.New("config").Funcs(funcMap).Parse(string(data))
 	if err != nil {
-		return nil, errors.New("Error parsing '" + configPath + "'\n  " + err.Error())
+		return nil, ConfigErrors{newConfigError(configPath, err, templateErrorLine)}
 	}
 
 	var b bytes.Buffer
-	tmpl.ExecuteTemplate(&b, "config", struct{}{})
+	if err = tmpl.ExecuteTemplate(&b, "config", struct{}{}); err != nil {
+		return nil, ConfigErrors{newConfigError(configPath, err, templateErrorLine)}
+	}
 
-	c := &yamlConfig{}
-	err = yaml.Unmarshal(b.Bytes(), &c)
+	var raw interface{}
+	if err = yaml.Unmarshal(b.Bytes(), &raw); err != nil {
+		return nil, ConfigErrors{newConfigError(configPath, err, yamlErrorLine)}
+	}
+	if errs := validateYaml(configPath, b.Bytes(), raw, global); len(errs) > 0 {
+		return nil, errs
+	}
 
-	if err != nil {
-		return nil, errors.New("Error parsing '" + configPath + "'\n  " + err.Error())
+	c := &yamlConfig{}
+	if err = yaml.Unmarshal(b.Bytes(), &c); err != nil {
+		return nil, ConfigErrors{newConfigError(configPath, err, yamlErrorLine)}
 	}
+	c.trackSources(configPath, indexYamlLines(b.String()), []string{})
 
 	return c, nil
 }
 
+// Keeps track of where the config and its nested blocks came from so that
+// we can tell where each setting was defined
+func (c *yamlConfig) trackSources(file string, lines map[string]int, path []string) {
+	c.file = file
+	c.lines = lines
+	c.path = path
+
+	blocks := map[string]*yamlConfig{"hack": c.Hack, "build": c.Build, "bootstrap": c.Bootstrap, "run": c.Run, "exec": c.Exec}
+	for name, block := range blocks {
+		if block != nil {
+			block.trackSources(file, lines, childPath(path, name))
+		}
+	}
+	for name, profile := range c.Profiles {
+		if profile != nil {
+			profile.trackSources(file, lines, childPath(childPath(path, "profiles"), name))
+		}
+	}
+}
+
+// The source of a setting defined on this config
+func (c *yamlConfig) source(path ...string) *ConfigSource {
+	fullPath := c.path
+	for _, name := range path {
+		fullPath = childPath(fullPath, name)
+	}
+	return &ConfigSource{Type: ConfigSourceFile, File: c.file, Line: lookupYamlLine(c.lines, fullPath)}
+}
+
+// Extracts the line number from template and yaml parsing errors
+func newConfigError(configPath string, err error, linePattern *regexp.Regexp) *ConfigError {
+	configErr := &ConfigError{File: configPath, Message: err.Error()}
+	if match := linePattern.FindStringSubmatch(err.Error()); match != nil {
+		configErr.Line, _ = strconv.Atoi(match[1])
+		configErr.Message = match[2]
+	}
+	return configErr
+}
+
 func assignYamlValues(yamlConf *yamlConfig, config *ProjectConfig) {
 	if yamlConf.RepositoryName != nil {
 		config.RepositoryName = *yamlConf.RepositoryName
+		config.SetSource("repository", yamlConf.source("repository"))
 	}
 	if yamlConf.SourceImage != nil {
 		config.SourceImage = *yamlConf.SourceImage
+		config.SetSource("source_image", yamlConf.source("source_image"))
+	}
+	if yamlConf.Registry != nil {
+		config.Registry = *yamlConf.Registry
+		config.SetSource("registry", yamlConf.source("registry"))
 	}
 	if yamlConf.CacheDir != nil {
 		config.CacheDir = *yamlConf.CacheDir
+		config.SetSource("cache_dir", yamlConf.source("cache_dir"))
 	}
 	if yamlConf.GuestDir != nil {
 		config.GuestDir = *yamlConf.GuestDir
+		config.SetSource("working_dir", yamlConf.source("working_dir"))
 	}
-	if yamlConf.Links != nil {
-		config.Defaults.Links = append(config.Defaults.Links, yamlConf.Links...)
-	}
-	if yamlConf.Volumes != nil {
-		volumes := yamlConf.Volumes
-		for i, vol := range volumes {
-			hostAndGuestDirs := strings.SplitN(vol, ":", 2)
-			hostDir, err := filepath.Abs(hostAndGuestDirs[0])
-			if err != nil {
-				panic(err)
-			}
-			guestDir := hostAndGuestDirs[1]
-			volumes[i] = hostDir + ":" + guestDir
-		}
-		config.Defaults.Volumes = append(config.Defaults.Volumes, volumes...)
+	for i, link := range yamlConf.Links {
+		config.SetSource(indexedKey("links", len(config.Defaults.Links)), yamlConf.source("links", indexedKey("", i)))
+		config.Defaults.Links = append(config.Defaults.Links, link)
 	}
-	if yamlConf.Env != nil {
-		for k, v := range yamlConf.Env {
-			config.Defaults.Env[k] = v
-		}
+	for i, volume := range yamlConf.expandVolumes(yamlConf.Volumes) {
+		config.SetSource(indexedKey("volumes", len(config.Defaults.Volumes)), yamlConf.source("volumes", indexedKey("", i)))
+		config.Defaults.Volumes = append(config.Defaults.Volumes, volume)
+	}
+	for i, port := range yamlConf.Ports {
+		config.SetSource(indexedKey("ports", len(config.Defaults.Publish)), yamlConf.source("ports", indexedKey("", i)))
+		config.Defaults.Publish = append(config.Defaults.Publish, port)
+	}
+	for k, v := range yamlConf.Env {
+		config.Defaults.Env[k] = v
+		config.SetSource("environment."+k, yamlConf.source("environment", k))
+	}
+	for i, step := range yamlConf.Provision {
+		config.SetSource(indexedKey("provision", len(config.Provision)), yamlConf.source("provision", indexedKey("", i)))
+		config.Provision = append(config.Provision, step)
+	}
+	for alias, yamlService := range yamlConf.Services {
+		assignYamlService(yamlConf, alias, yamlService, config)
+	}
+	if yamlConf.Retention != nil {
+		assignYamlRetention(yamlConf, config)
+	}
+	if yamlConf.IgnoreChanges != nil {
+		assignYamlIgnoreChanges(yamlConf, config)
+	}
+	if yamlConf.Build != nil && yamlConf.Build.CacheKeys != nil {
+		assignYamlCacheKeys(yamlConf.Build, config)
 	}
 
-	if yamlConf.Hack != nil {
-		if yamlConf.Hack.Links != nil {
-			config.HackOpts.Links = append(config.HackOpts.Links, yamlConf.Hack.Links...)
-		}
-		if yamlConf.Hack.Volumes != nil {
-			config.HackOpts.Volumes = append(config.HackOpts.Volumes, yamlConf.Hack.Volumes...)
+	assignYamlRunOpts(yamlConf.Hack, config.HackOpts, config, "hack")
+	assignYamlRunOpts(yamlConf.Build, config.BuildOpts, config, "build")
+	assignYamlRunOpts(yamlConf.Bootstrap, config.BootstrapOpts, config, "bootstrap")
+	assignYamlRunOpts(yamlConf.Run, config.RunOpts, config, "run")
+	assignYamlRunOpts(yamlConf.Exec, config.ExecOpts, config, "exec")
+}
+
+// Assigns the values of a command specific block (like `hack:`) to the
+// options for that command
+func assignYamlRunOpts(yamlConf *yamlConfig, opts *DockerRunOpts, config *ProjectConfig, name string) {
+	if yamlConf == nil {
+		return
+	}
+
+	if yamlConf.Privileged != nil {
+		opts.Privileged = yamlConf.Privileged
+		config.SetSource(name+".privileged", yamlConf.source("privileged"))
+	}
+	if yamlConf.GuestDir != nil {
+		opts.Workdir = *yamlConf.GuestDir
+		config.SetSource(name+".working_dir", yamlConf.source("working_dir"))
+	}
+	for i, link := range yamlConf.Links {
+		config.SetSource(indexedKey(name+".links", len(opts.Links)), yamlConf.source("links", indexedKey("", i)))
+		opts.Links = append(opts.Links, link)
+	}
+	for i, volume := range yamlConf.expandVolumes(yamlConf.Volumes) {
+		config.SetSource(indexedKey(name+".volumes", len(opts.Volumes)), yamlConf.source("volumes", indexedKey("", i)))
+		opts.Volumes = append(opts.Volumes, volume)
+	}
+	for i, port := range yamlConf.Ports {
+		config.SetSource(indexedKey(name+".ports", len(opts.Publish)), yamlConf.source("ports", indexedKey("", i)))
+		opts.Publish = append(opts.Publish, port)
+	}
+	for k, v := range yamlConf.Env {
+		opts.Env[k] = v
+		config.SetSource(name+".environment."+k, yamlConf.source("environment", k))
+	}
+}
+
+func assignProjectYamlValues(yamlConf *yamlConfig, config *ProjectConfig) {
+	assignYamlValues(yamlConf, config)
+	if yamlConf.Privileged != nil {
+		config.Defaults.Privileged = yamlConf.Privileged
+		config.SetSource("privileged", yamlConf.source("privileged"))
+	}
+}
+
+func assignYamlService(yamlConf *yamlConfig, alias string, yamlService *yamlService, config *ProjectConfig) {
+	var service *ServiceConfig
+	for _, existing := range config.Services {
+		if existing.Alias == alias {
+			service = existing
 		}
-		if yamlConf.Hack.Env != nil {
-			for k, v := range yamlConf.Hack.Env {
-				config.HackOpts.Env[k] = v
-			}
+	}
+	if service == nil {
+		service = &ServiceConfig{Alias: alias, Env: make(map[string]string)}
+		config.Services = append(config.Services, service)
+	}
+	if yamlService == nil {
+		return
+	}
+
+	key := "services." + alias + "."
+	if yamlService.Name != nil {
+		service.Name = *yamlService.Name
+		config.SetSource(key+"name", yamlConf.source("services", alias, "name"))
+	}
+	if yamlService.Image != nil {
+		service.Image = *yamlService.Image
+		config.SetSource(key+"image", yamlConf.source("services", alias, "image"))
+	}
+	for i, volume := range yamlConf.expandVolumes(yamlService.Volumes) {
+		config.SetSource(indexedKey(key+"volumes", len(service.Volumes)), yamlConf.source("services", alias, "volumes", indexedKey("", i)))
+		service.Volumes = append(service.Volumes, volume)
+	}
+	for i, port := range yamlService.Ports {
+		config.SetSource(indexedKey(key+"ports", len(service.Publish)), yamlConf.source("services", alias, "ports", indexedKey("", i)))
+		service.Publish = append(service.Publish, port)
+	}
+	for k, v := range yamlService.Env {
+		service.Env[k] = v
+		config.SetSource(key+"environment."+k, yamlConf.source("services", alias, "environment", k))
+	}
+}
+
+// Rules from the project config are applied on top of the ones from the
+// home dir, invalid ages are reported when validating the config
+func assignYamlRetention(yamlConf *yamlConfig, config *ProjectConfig) {
+	if config.Retention == nil {
+		config.Retention = &RetentionPolicy{}
+	}
+	if yamlConf.Retention.KeepLast != nil {
+		config.Retention.KeepLast = *yamlConf.Retention.KeepLast
+		config.SetSource("retention.keep_last", yamlConf.source("retention", "keep_last"))
+	}
+	if yamlConf.Retention.KeepNewerThan != nil {
+		config.Retention.KeepNewerThan, _ = ParseRetentionAge(*yamlConf.Retention.KeepNewerThan)
+		config.SetSource("retention.keep_newer_than", yamlConf.source("retention", "keep_newer_than"))
+	}
+}
+
+// Cache keys from the project config replace the ones from the home dir so
+// that an empty list disables build caching
+func assignYamlCacheKeys(yamlConf *yamlConfig, config *ProjectConfig) {
+	config.CacheKeys = []string{}
+	for i, key := range yamlConf.CacheKeys {
+		config.SetSource(indexedKey("build.cache_keys", i), yamlConf.source("cache_keys", indexedKey("", i)))
+		config.CacheKeys = append(config.CacheKeys, key)
+	}
+}
+
+// Like cache keys, paths ignored by the project config replace the ones from
+// the home dir and the defaults
+func assignYamlIgnoreChanges(yamlConf *yamlConfig, config *ProjectConfig) {
+	config.IgnoreChanges = []string{}
+	for i, pattern := range yamlConf.IgnoreChanges {
+		config.SetSource(indexedKey("ignore_changes", i), yamlConf.source("ignore_changes", indexedKey("", i)))
+		config.IgnoreChanges = append(config.IgnoreChanges, pattern)
+	}
+}
+
+// Makes the host path of volumes relative to the dir of the config file
+// they were defined on absolute, invalid volumes are reported when
+// validating the config and are kept as is
+func (c *yamlConfig) expandVolumes(volumes []string) []string {
+	baseDir := filepath.Dir(c.file)
+	expanded := []string{}
+	for _, volume := range volumes {
+		volume = c.bindAmbiguousVolume(volume, baseDir)
+		if expandedVolume, err := ExpandVolume(volume, baseDir); err == nil {
+			volume = expandedVolume
 		}
+		expanded = append(expanded, volume)
+	}
+	return expanded
+}
+
+// Versions before named volumes were supported mounted bare names (like
+// `vendor:/vendor`) from the dir of the config file, so those keep being
+// bind mounted when a path by that name exists there
+func (c *yamlConfig) bindAmbiguousVolume(volume, baseDir string) string {
+	name := strings.SplitN(volume, ":", 2)[0]
+	if !validVolumeName.MatchString(name) || !strings.Contains(volume, ":") {
+		return volume
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, name)); err != nil {
+		return volume
+	}
+	log.Warning("Volume '%s' on %s matches an existing path and will be bind mounted, prefix it with './' or rename it to use a named volume", volume, c.file)
+	return "./" + volume
+}
+
+var validVolumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Validates a volume in the `host-dir:/guest-dir[:ro|rw]` format, making its
+// host dir absolute by resolving it relative to the provided dir. Named
+// volumes (like `data:/var/lib/data`) are kept as is.
+func ExpandVolume(volume, baseDir string) (string, error) {
+	parts := strings.Split(volume, ":")
+	valid := len(parts) >= 2 && len(parts) <= 3 && parts[0] != "" && strings.HasPrefix(parts[1], "/")
+	if valid && len(parts) == 3 {
+		valid = parts[2] == "ro" || parts[2] == "rw"
+	}
+	if !valid {
+		return "", errors.New("Invalid volume '" + volume + "', expected 'host-dir:/guest-dir[:ro|rw]'")
+	}
+
+	if validVolumeName.MatchString(parts[0]) {
+		return volume, nil
+	}
+	if !filepath.IsAbs(parts[0]) {
+		parts[0] = filepath.Join(baseDir, parts[0])
 	}
+	return strings.Join(parts, ":"), nil
 }
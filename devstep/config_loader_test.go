@@ -5,6 +5,7 @@ import (
 	"github.com/fgrehm/devstep-cli/devstep"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -298,6 +299,261 @@ cache_dir:    '{{env "BAR"}}/cache-dir'
 	equals(t, "bar-val/cache-dir", config.CacheDir)
 }
 
+func Test_MergeProvisioningSteps(t *testing.T) {
+	tempHomeDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempHomeDir+"/devstep.yml", `
+provision:
+- ['configure-addons', 'heroku-toolbelt']
+`)
+	defer os.RemoveAll(tempHomeDir)
+
+	tempProjDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempProjDir+"/devstep.yml", `
+provision:
+- ['configure-addons', 'redis']
+- ['bundle', 'exec', 'rake', 'db:setup']
+`)
+	defer os.RemoveAll(tempProjDir)
+
+	loader, _ := newConfigLoader(tempHomeDir, tempProjDir)
+	config, err := loader.Load()
+
+	ok(t, err)
+
+	equals(t, [][]string{
+		[]string{"configure-addons", "heroku-toolbelt"},
+		[]string{"configure-addons", "redis"},
+		[]string{"bundle", "exec", "rake", "db:setup"},
+	}, config.Provision)
+}
+
+func Test_LoadServices(t *testing.T) {
+	tempHomeDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempHomeDir+"/devstep.yml", `
+services:
+  db:
+    image: 'postgres:9.3'
+    environment:
+      POSTGRES_USER: 'devstep'
+`)
+	defer os.RemoveAll(tempHomeDir)
+
+	tempProjDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempProjDir+"/devstep.yml", `
+services:
+  redis:
+    image: 'redis'
+    name: 'custom-redis'
+  db:
+    image: 'postgres:9.4'
+    environment:
+      POSTGRES_PASSWORD: 'secret'
+    volumes:
+    - '/host/data:/var/lib/postgresql/data'
+    ports:
+    - '5432:5432'
+`)
+	defer os.RemoveAll(tempProjDir)
+
+	loader, _ := newConfigLoader(tempHomeDir, tempProjDir)
+	config, err := loader.Load()
+
+	ok(t, err)
+
+	equals(t, 2, len(config.Services))
+
+	db := config.Services[0]
+	equals(t, "db", db.Alias)
+	equals(t, filepath.Base(tempProjDir)+"-db", db.Name)
+	equals(t, "postgres:9.4", db.Image)
+	equals(t, map[string]string{"POSTGRES_USER": "devstep", "POSTGRES_PASSWORD": "secret"}, db.Env)
+	equals(t, []string{"/host/data:/var/lib/postgresql/data"}, db.Volumes)
+	equals(t, []string{"5432:5432"}, db.Publish)
+
+	redis := config.Services[1]
+	equals(t, "redis", redis.Alias)
+	equals(t, "custom-redis", redis.Name)
+	equals(t, "redis", redis.Image)
+}
+
+func Test_ServicesRequireAnImage(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+services:
+  db:
+    name: 'db'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+
+	_, err := loader.Load()
+	assert(t, err != nil, "Service without an image was allowed")
+}
+
+func Test_LoadCommandSpecificConfigs(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+privileged: false
+hack:
+  privileged: false
+  working_dir: '/path/to/guest/dir/src'
+build:
+  privileged: true
+  links:
+  - "bcname:bname"
+  volumes:
+  - "/b/host/dir:/b/guest/dir"
+  environment:
+    BUILD: "1"
+bootstrap:
+  environment:
+    BOOTSTRAP: "1"
+run:
+  links:
+  - "rcname:rname"
+exec:
+  environment:
+    TERM: "xterm"
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	config, err := loader.Load()
+
+	ok(t, err)
+
+	assert(t, !*config.Defaults.Privileged, "Privileged is set")
+	assert(t, !*config.HackOpts.Privileged, "Hack privileged is set")
+	equals(t, "/path/to/guest/dir/src", config.HackOpts.Workdir)
+
+	assert(t, *config.BuildOpts.Privileged, "Build privileged is not set")
+	equals(t, []string{"bcname:bname"}, config.BuildOpts.Links)
+	equals(t, []string{"/b/host/dir:/b/guest/dir"}, config.BuildOpts.Volumes)
+	equals(t, map[string]string{"BUILD": "1"}, config.BuildOpts.Env)
+
+	equals(t, map[string]string{"BOOTSTRAP": "1"}, config.BootstrapOpts.Env)
+	equals(t, []string{"rcname:rname"}, config.RunOpts.Links)
+	equals(t, map[string]string{"TERM": "xterm"}, config.ExecOpts.Env)
+}
+
+func Test_CommandPrivilegedCantBeSetFromHomeDir(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempDir+"/devstep.yml", `
+build:
+  privileged: true
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader(tempDir, "")
+
+	_, err := loader.Load()
+	assert(t, err != nil, "Privileged was allowed from home dir build config")
+}
+
+func Test_ProfilesPrecedence(t *testing.T) {
+	tempHomeDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempHomeDir+"/devstep.yml", `
+source_image: 'home/image:tag'
+cache_dir:    '/home/cache/dir'
+links:
+- "home:link"
+environment:
+  FROM_HOME: "home"
+  OVERRIDEN: "home"
+profiles:
+  ci:
+    cache_dir: '/home-ci/cache/dir'
+    links:
+    - "home-ci:link"
+    environment:
+      FROM_HOME_PROFILE: "home-ci"
+      OVERRIDEN: "home-ci"
+`)
+	defer os.RemoveAll(tempHomeDir)
+
+	tempProjDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempProjDir+"/devstep.yml", `
+source_image: 'project/image:tag'
+cache_dir:    '/project/cache/dir'
+links:
+- "project:link"
+environment:
+  FROM_PROJECT: "project"
+  OVERRIDEN: "project"
+profiles:
+  ci:
+    source_image: 'project-ci/image:tag'
+    privileged: true
+    links:
+    - "project-ci:link"
+    volumes:
+    - "/ci/host/dir:/ci/guest/dir"
+    environment:
+      OVERRIDEN: "project-ci"
+  other:
+    source_image: 'other/image:tag'
+`)
+	defer os.RemoveAll(tempProjDir)
+
+	loader, _ := newConfigLoaderWithProfile(tempHomeDir, tempProjDir, "ci")
+	config, err := loader.Load()
+
+	ok(t, err)
+
+	equals(t, "ci", config.Profile)
+	equals(t, "project-ci/image:tag", config.SourceImage)
+	equals(t, "/home-ci/cache/dir", config.CacheDir)
+	assert(t, *config.Defaults.Privileged, "Privileged is not set")
+	equals(t, []string{"home:link", "project:link", "home-ci:link", "project-ci:link"}, config.Defaults.Links)
+	equals(t, []string{"/ci/host/dir:/ci/guest/dir"}, config.Defaults.Volumes)
+	equals(t, "home", config.Defaults.Env["FROM_HOME"])
+	equals(t, "project", config.Defaults.Env["FROM_PROJECT"])
+	equals(t, "home-ci", config.Defaults.Env["FROM_HOME_PROFILE"])
+	equals(t, "project-ci", config.Defaults.Env["OVERRIDEN"])
+
+	loader, _ = newConfigLoader(tempHomeDir, tempProjDir)
+	config, err = loader.Load()
+
+	ok(t, err)
+
+	equals(t, "", config.Profile)
+	equals(t, "project/image:tag", config.SourceImage)
+	equals(t, "/project/cache/dir", config.CacheDir)
+	assert(t, config.Defaults.Privileged == nil, "Privileged is set")
+	equals(t, "project", config.Defaults.Env["OVERRIDEN"])
+}
+
+func Test_UnknownProfile(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+profiles:
+  ci:
+    source_image: 'ci/image:tag'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoaderWithProfile("", tempDir, "unknown")
+
+	_, err := loader.Load()
+	assert(t, err != nil, "Unknown profile was allowed")
+}
+
+func Test_PrivilegedCantBeSetFromHomeDirProfile(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempDir+"/devstep.yml", `
+profiles:
+  ci:
+    privileged: true
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoaderWithProfile(tempDir, "", "ci")
+
+	_, err := loader.Load()
+	assert(t, err != nil, "Privileged was allowed from home dir profile")
+}
+
 func Test_RepositoryNameCantBeSetFromHomeDir(t *testing.T) {
 	tempDir, _ := ioutil.TempDir("", "devstep-project-")
 	writeFile(tempDir+"/devstep.yml", "repository: 'custom/repository'")
@@ -320,9 +576,69 @@ func Test_PrivilegedCantBeSetFromHomeDir(t *testing.T) {
 	assert(t, err != nil, "Privileged was allowed from home dir")
 }
 
+func Test_ExpandVolume(t *testing.T) {
+	volume, err := devstep.ExpandVolume("/host/dir:/guest/dir", "/project")
+	ok(t, err)
+	equals(t, "/host/dir:/guest/dir", volume)
+
+	volume, err = devstep.ExpandVolume("./data:/guest/data:ro", "/project")
+	ok(t, err)
+	equals(t, "/project/data:/guest/data:ro", volume)
+
+	volume, err = devstep.ExpandVolume("../shared/lib:/guest/lib:rw", "/project")
+	ok(t, err)
+	equals(t, "/shared/lib:/guest/lib:rw", volume)
+
+	volume, err = devstep.ExpandVolume("pgdata:/var/lib/postgresql/data", "/project")
+	ok(t, err)
+	equals(t, "pgdata:/var/lib/postgresql/data", volume)
+
+	for _, invalid := range []string{"/no/guest/dir", "/host:relative/guest", "/host:/guest:rx", ":/guest", "/a:/b:ro:rw"} {
+		_, err = devstep.ExpandVolume(invalid, "/project")
+		assert(t, err != nil, "Volume was accepted: "+invalid)
+	}
+}
+
+func Test_RelativeVolumesAreResolvedFromTheConfigFileDir(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+volumes:
+- './data:/guest/data:ro'
+- 'named:/guest/named'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	config, err := loader.Load()
+	ok(t, err)
+
+	equals(t, []string{tempDir + "/data:/guest/data:ro", "named:/guest/named"}, config.Defaults.Volumes)
+}
+
+func Test_BareVolumeNamesMatchingAPathAreBindMounted(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	os.Mkdir(tempDir+"/vendor", 0755)
+	writeFile(tempDir+"/devstep.yml", `
+volumes:
+- 'vendor:/guest/vendor'
+- 'named:/guest/named'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	config, err := loader.Load()
+	ok(t, err)
+
+	equals(t, []string{tempDir + "/vendor:/guest/vendor", "named:/guest/named"}, config.Defaults.Volumes)
+}
+
 func newConfigLoader(homeDir, projectDir string) (devstep.ConfigLoader, *MockClient) {
+	return newConfigLoaderWithProfile(homeDir, projectDir, "")
+}
+
+func newConfigLoaderWithProfile(homeDir, projectDir, profile string) (devstep.ConfigLoader, *MockClient) {
 	client := NewMockClient()
-	loader := devstep.NewConfigLoader(client, homeDir, projectDir)
+	loader := devstep.NewConfigLoader(client, homeDir, projectDir, profile)
 
 	return loader, client
 }
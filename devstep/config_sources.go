@@ -0,0 +1,225 @@
+package devstep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Types of places a setting can come from
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceFile    = "file"
+	ConfigSourcePlugin  = "plugin"
+	ConfigSourceFlag    = "flag"
+	ConfigSourceEnv     = "env"
+)
+
+// Where a configuration value came from
+type ConfigSource struct {
+	Type   string `json:"type"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Plugin string `json:"plugin,omitempty"`
+	Flag   string `json:"flag,omitempty"`
+	EnvVar string `json:"env,omitempty"`
+}
+
+var defaultSource = &ConfigSource{Type: ConfigSourceDefault}
+
+func (s *ConfigSource) String() string {
+	switch s.Type {
+	case ConfigSourceFile:
+		if s.Line > 0 {
+			return s.File + ":" + strconv.Itoa(s.Line)
+		}
+		return s.File
+	case ConfigSourcePlugin:
+		return "plugin " + s.Plugin
+	case ConfigSourceFlag:
+		return "flag --" + s.Flag
+	case ConfigSourceEnv:
+		return "env " + s.EnvVar
+	}
+	return s.Type
+}
+
+// A setting of the final configuration along with where it came from
+type ConfigEntry struct {
+	Key    string        `json:"key"`
+	Value  string        `json:"value"`
+	Source *ConfigSource `json:"source"`
+}
+
+// Records where a setting came from, keys are named after the yaml settings
+// (like `hack.environment.FOO` or `volumes[0]`)
+func (c *ProjectConfig) SetSource(key string, source *ConfigSource) {
+	if c.Sources == nil {
+		c.Sources = make(map[string]*ConfigSource)
+	}
+	c.Sources[key] = source
+}
+
+// Where a setting came from, settings we don't know about are assumed to be
+// defaults
+func (c *ProjectConfig) Source(key string) *ConfigSource {
+	if source, found := c.Sources[key]; found {
+		return source
+	}
+	return defaultSource
+}
+
+// Lists every setting of the configuration along with its source
+func (c *ProjectConfig) Explain() []*ConfigEntry {
+	entries := []*ConfigEntry{}
+	add := func(key, value string) {
+		entries = append(entries, &ConfigEntry{Key: key, Value: value, Source: c.Source(key)})
+	}
+
+	add("source_image", c.SourceImage)
+	add("base_image", c.BaseImage)
+	add("repository", c.RepositoryName)
+	if c.Registry != "" {
+		add("registry", c.Registry)
+	}
+	add("host_dir", c.HostDir)
+	add("working_dir", c.GuestDir)
+	add("cache_dir", c.CacheDir)
+	if c.Profile != "" {
+		add("profile", c.Profile)
+	}
+
+	explainRunOpts(c.Defaults, "", add)
+	blocks := []struct {
+		name string
+		opts *DockerRunOpts
+	}{
+		{"hack", c.HackOpts},
+		{"build", c.BuildOpts},
+		{"bootstrap", c.BootstrapOpts},
+		{"run", c.RunOpts},
+		{"exec", c.ExecOpts},
+	}
+	for _, block := range blocks {
+		explainRunOpts(block.opts, block.name+".", add)
+	}
+
+	for i, step := range c.Provision {
+		add(indexedKey("provision", i), strings.Join(step, " "))
+	}
+
+	for i, pattern := range c.IgnoreChanges {
+		add(indexedKey("ignore_changes", i), pattern)
+	}
+	for i, key := range c.CacheKeys {
+		add(indexedKey("build.cache_keys", i), key)
+	}
+
+	if c.Retention != nil {
+		if c.Retention.KeepLast > 0 {
+			add("retention.keep_last", strconv.Itoa(c.Retention.KeepLast))
+		}
+		if c.Retention.KeepNewerThan > 0 {
+			add("retention.keep_newer_than", c.Retention.KeepNewerThan.String())
+		}
+	}
+
+	for _, service := range c.Services {
+		prefix := "services." + service.Alias + "."
+		add(prefix+"name", service.Name)
+		add(prefix+"image", service.Image)
+		for _, k := range sortedKeys(service.Env) {
+			add(prefix+"environment."+k, service.Env[k])
+		}
+		for i, volume := range service.Volumes {
+			add(indexedKey(prefix+"volumes", i), volume)
+		}
+		for i, port := range service.Publish {
+			add(indexedKey(prefix+"ports", i), port)
+		}
+	}
+
+	return entries
+}
+
+// A hash of the settings that affect the environment, used for telling
+// whether containers and images were created with the current configuration.
+// Settings that are specific to a checkout or machine are left out so that
+// shared images match the configuration of other developers.
+func (c *ProjectConfig) Hash() string {
+	entries := []*ConfigEntry{}
+	for _, entry := range c.Explain() {
+		// The base image changes after each build and the dirs depend on
+		// where the project was checked out
+		switch {
+		case entry.Key == "base_image", entry.Key == "host_dir", entry.Key == "cache_dir":
+			continue
+		case devstepVariable(entry.Key):
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return hashEntries(entries)
+}
+
+// Variables set by devstep itself that change between invocations (like the
+// container name, which is unique for each of them, or the log level)
+func devstepVariable(key string) bool {
+	for _, name := range []string{"DEVSTEP_CONTAINER_NAME", "DEVSTEP_LOG", "DEVSTEP_PROFILE"} {
+		if strings.HasSuffix(key, "environment."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashEntries(entries []*ConfigEntry) string {
+	hash := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintf(hash, "%s=%s\n", entry.Key, hashedValue(entry))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Only the container side of volumes is hashed as host paths differ between
+// machines
+func hashedValue(entry *ConfigEntry) string {
+	if !strings.Contains(entry.Key, "volumes[") || !strings.HasPrefix(entry.Value, "/") {
+		return entry.Value
+	}
+	if i := strings.Index(entry.Value, ":"); i >= 0 {
+		return entry.Value[i+1:]
+	}
+	return entry.Value
+}
+
+func explainRunOpts(opts *DockerRunOpts, prefix string, add func(key, value string)) {
+	if opts == nil {
+		return
+	}
+
+	if opts.Privileged != nil {
+		add(prefix+"privileged", fmt.Sprintf("%v", *opts.Privileged))
+	}
+	if opts.Workdir != "" {
+		add(prefix+"working_dir", opts.Workdir)
+	}
+	for i, link := range opts.Links {
+		add(indexedKey(prefix+"links", i), link)
+	}
+	for i, volume := range opts.Volumes {
+		add(indexedKey(prefix+"volumes", i), volume)
+	}
+	for i, port := range opts.Publish {
+		add(indexedKey(prefix+"ports", i), port)
+	}
+	for _, k := range sortedKeys(opts.Env) {
+		add(prefix+"environment."+k, opts.Env[k])
+	}
+}
+
+func indexedKey(key string, index int) string {
+	return key + "[" + strconv.Itoa(index) + "]"
+}
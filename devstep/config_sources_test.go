@@ -0,0 +1,134 @@
+package devstep_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_TracksWhereSettingsCameFrom(t *testing.T) {
+	tempHomeDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempHomeDir+"/devstep.yml", `
+cache_dir: '/home/cache'
+environment:
+  FROM_HOME: 'home'
+`)
+	defer os.RemoveAll(tempHomeDir)
+	tempProjectDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempProjectDir+"/devstep.yml", `
+source_image: 'source/image:tag'
+volumes:
+- '/host/dir:/guest/dir'
+hack:
+  environment:
+    FROM_HACK: 'hack'
+profiles:
+  ci:
+    environment:
+      FROM_HOME: 'profile'
+`)
+	defer os.RemoveAll(tempProjectDir)
+
+	loader, _ := newConfigLoaderWithProfile(tempHomeDir, tempProjectDir, "ci")
+	config, err := loader.Load()
+	ok(t, err)
+
+	homeFile := tempHomeDir + "/devstep.yml"
+	projectFile := tempProjectDir + "/devstep.yml"
+
+	equals(t, &devstep.ConfigSource{Type: devstep.ConfigSourceFile, File: homeFile, Line: 2}, config.Source("cache_dir"))
+	equals(t, &devstep.ConfigSource{Type: devstep.ConfigSourceFile, File: projectFile, Line: 2}, config.Source("source_image"))
+	equals(t, &devstep.ConfigSource{Type: devstep.ConfigSourceFile, File: projectFile, Line: 4}, config.Source("volumes[0]"))
+	equals(t, &devstep.ConfigSource{Type: devstep.ConfigSourceFile, File: projectFile, Line: 7}, config.Source("hack.environment.FROM_HACK"))
+	equals(t, &devstep.ConfigSource{Type: devstep.ConfigSourceFile, File: projectFile, Line: 11}, config.Source("environment.FROM_HOME"))
+	equals(t, devstep.ConfigSourceDefault, config.Source("repository").Type)
+	equals(t, projectFile+":4", config.Source("volumes[0]").String())
+}
+
+func Test_TracksSettingsFromPlugins(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-plugins-")
+	defer os.RemoveAll(tempDir)
+	pluginPath := tempDir + "/plugin.js"
+	writeFile(pluginPath, `
+devstep.on('configLoaded', function(config) {
+  config.setEnv('FROM_PLUGIN', 'plugin');
+  config.addVolume('/plugin/dir:/guest/dir');
+});
+`)
+
+	config := &devstep.ProjectConfig{Defaults: &devstep.DockerRunOpts{Env: map[string]string{}}}
+	runtime := devstep.NewPluginRuntime(config)
+	ok(t, runtime.Load(pluginPath))
+	ok(t, runtime.Trigger("configLoaded"))
+
+	equals(t, "plugin", config.Defaults.Env["FROM_PLUGIN"])
+	equals(t, &devstep.ConfigSource{Type: devstep.ConfigSourcePlugin, Plugin: pluginPath}, config.Source("environment.FROM_PLUGIN"))
+	equals(t, &devstep.ConfigSource{Type: devstep.ConfigSourcePlugin, Plugin: pluginPath}, config.Source("volumes[0]"))
+}
+
+func Test_Explain(t *testing.T) {
+	privileged := true
+	config := &devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		Defaults: &devstep.DockerRunOpts{
+			Privileged: &privileged,
+			Env:        map[string]string{"FOO": "bar"},
+		},
+		HackOpts: &devstep.DockerRunOpts{Links: []string{"db:db"}},
+	}
+	config.SetSource("environment.FOO", &devstep.ConfigSource{Type: devstep.ConfigSourceFlag, Flag: "env"})
+
+	entries := map[string]*devstep.ConfigEntry{}
+	for _, entry := range config.Explain() {
+		entries[entry.Key] = entry
+	}
+
+	equals(t, "source/image:tag", entries["source_image"].Value)
+	equals(t, devstep.ConfigSourceDefault, entries["source_image"].Source.Type)
+	equals(t, "true", entries["privileged"].Value)
+	equals(t, "bar", entries["environment.FOO"].Value)
+	equals(t, "flag --env", entries["environment.FOO"].Source.String())
+	equals(t, "db:db", entries["hack.links[0]"].Value)
+}
+
+func Test_ConfigHash(t *testing.T) {
+	newConfig := func(containerName string) *devstep.ProjectConfig {
+		return &devstep.ProjectConfig{
+			SourceImage: "source/image:tag",
+			BaseImage:   "source/image:tag",
+			Defaults: &devstep.DockerRunOpts{
+				Env: map[string]string{"FOO": "bar", "DEVSTEP_CONTAINER_NAME": containerName},
+			},
+		}
+	}
+
+	config := newConfig("project-20150101000000")
+	hash := config.Hash()
+	equals(t, hash, config.Hash())
+
+	other := newConfig("project-20150102000000")
+	other.BaseImage = "repo/name:latest"
+	equals(t, hash, other.Hash())
+
+	other.Defaults.Env["DEVSTEP_LOG"] = "DEBUG"
+	other.Defaults.Env["DEVSTEP_PROFILE"] = "ci"
+	equals(t, hash, other.Hash())
+
+	other.HostDir = "/home/someone/project"
+	other.CacheDir = "/home/someone/.devstep/cache"
+	equals(t, hash, other.Hash())
+
+	config.Defaults.Volumes = []string{"/home/me/.ssh:/home/devstep/.ssh:ro"}
+	hash = config.Hash()
+	other.Defaults.Volumes = []string{"/Users/someone/.ssh:/home/devstep/.ssh:ro"}
+	equals(t, hash, other.Hash())
+
+	other.Defaults.Volumes = []string{"/Users/someone/.ssh:/home/devstep/.ssh"}
+	assert(t, hash != other.Hash(), "Hash did not change along with the volume mode")
+
+	other.Defaults.Volumes = config.Defaults.Volumes
+	other.Defaults.Env["FOO"] = "baz"
+	assert(t, hash != other.Hash(), "Hash did not change along with the config")
+}
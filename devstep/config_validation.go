@@ -0,0 +1,407 @@
+package devstep
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A problem found on a devstep.yml file
+type ConfigError struct {
+	File    string
+	Line    int // 0 when the line is unknown
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+	return e.File + ": " + e.Message
+}
+
+// All problems found while loading the configuration
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "Invalid configuration:\n  " + strings.Join(messages, "\n  ")
+}
+
+func (e ConfigErrors) Len() int      { return len(e) }
+func (e ConfigErrors) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e ConfigErrors) Less(i, j int) bool {
+	if e[i].File != e[j].File {
+		return e[i].File < e[j].File
+	}
+	if e[i].Line != e[j].Line {
+		return e[i].Line < e[j].Line
+	}
+	return e[i].Message < e[j].Message
+}
+
+// Keys that are allowed inside the command specific blocks (like `hack:`)
+var commandBlockKeys = map[string]bool{
+	"privileged":  true,
+	"working_dir": true,
+	"links":       true,
+	"volumes":     true,
+	"ports":       true,
+	"environment": true,
+}
+
+// Keys that are only allowed inside the `build:` block
+var buildBlockKeys = map[string]bool{
+	"cache_keys": true,
+}
+
+var commandBlocks = map[string]bool{
+	"hack":      true,
+	"build":     true,
+	"bootstrap": true,
+	"run":       true,
+	"exec":      true,
+}
+
+var (
+	validLink     = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*(:[a-zA-Z0-9][a-zA-Z0-9_.-]*)?$`)
+	validEnvName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	validRegistry = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*(:[0-9]+)?$`)
+)
+
+type configValidator struct {
+	file   string
+	global bool
+	lines  map[string]int
+	errors ConfigErrors
+}
+
+// Checks the raw yaml data from a config file against the settings devstep
+// knows about, global configs are the ones from the home dir
+func validateYaml(file string, data []byte, raw interface{}, global bool) ConfigErrors {
+	v := &configValidator{
+		file:   file,
+		global: global,
+		lines:  indexYamlLines(string(data)),
+	}
+	v.validate(raw, reflect.TypeOf(yamlConfig{}), []string{}, "")
+	sort.Sort(v.errors)
+	return v.errors
+}
+
+func (v *configValidator) addError(path []string, format string, args ...interface{}) {
+	v.errors = append(v.errors, &ConfigError{
+		File:    v.file,
+		Line:    v.lineFor(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *configValidator) lineFor(path []string) int {
+	return lookupYamlLine(v.lines, path)
+}
+
+func (v *configValidator) validate(value interface{}, t reflect.Type, path []string, key string) {
+	if value == nil {
+		return
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		v.validateStruct(value, t, path, key)
+	case reflect.Map:
+		values, ok := value.(map[interface{}]interface{})
+		if !ok {
+			v.addError(path, "Expected '%s' to be a mapping", key)
+			return
+		}
+		for k, val := range values {
+			name := fmt.Sprint(k)
+			if key == "environment" && !validEnvName.MatchString(name) {
+				v.addError(childPath(path, name), "Invalid environment variable name '%s'", name)
+			}
+			v.validate(val, t.Elem(), childPath(path, name), key)
+		}
+	case reflect.Slice:
+		values, ok := value.([]interface{})
+		if !ok {
+			v.addError(path, "Expected '%s' to be a list", key)
+			return
+		}
+		for i, val := range values {
+			itemPath := childPath(path, "["+strconv.Itoa(i)+"]")
+			v.validate(val, t.Elem(), itemPath, key)
+			if str, ok := val.(string); ok {
+				v.validateItem(itemPath, key, str)
+			}
+		}
+	case reflect.Int:
+		if number, ok := value.(int); !ok || number < 0 {
+			v.addError(path, "Expected '%s' to be a positive number", key)
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			v.addError(path, "Expected '%s' to be true or false", key)
+		}
+	case reflect.String:
+		switch value.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			v.addError(path, "Expected '%s' to be a string", key)
+		default:
+			v.validateValue(path, key, fmt.Sprint(value))
+		}
+	}
+}
+
+func (v *configValidator) validateStruct(value interface{}, t reflect.Type, path []string, key string) {
+	values, ok := value.(map[interface{}]interface{})
+	if !ok {
+		v.addError(path, "Expected '%s' to be a mapping", key)
+		return
+	}
+
+	inCommandBlock := commandBlocks[key]
+	inProfile := false
+	for _, name := range path {
+		if name == "profiles" {
+			inProfile = true
+		}
+	}
+
+	fields := yamlFields(t)
+	if inCommandBlock {
+		for name := range fields {
+			if !commandBlockKeys[name] && !(key == "build" && buildBlockKeys[name]) {
+				delete(fields, name)
+			}
+		}
+	}
+	for k, val := range values {
+		name := fmt.Sprint(k)
+		keyPath := childPath(path, name)
+		field, known := fields[name]
+		if !known {
+			message := "Unknown key '" + name + "'"
+			if suggestion := suggestKey(name, fields); suggestion != "" {
+				message += ", did you mean '" + suggestion + "'?"
+			}
+			v.addError(keyPath, "%s", message)
+			continue
+		}
+
+		if v.global && name == "repository" {
+			v.addError(keyPath, "Repository name can't be set globally")
+		}
+		if v.global && name == "privileged" {
+			v.addError(keyPath, "Privileged can't be set globally")
+		}
+		if inProfile && name == "profiles" {
+			v.addError(keyPath, "Profiles can't be nested")
+		}
+		if !inCommandBlock && buildBlockKeys[name] {
+			v.addError(keyPath, "'%s' can only be set inside the build block", name)
+		}
+
+		v.validate(val, field.Type, keyPath, name)
+	}
+}
+
+// Checks list items whose format we know about
+func (v *configValidator) validateItem(path []string, key, item string) {
+	switch key {
+	case "volumes":
+		if _, err := ExpandVolume(item, "/"); err != nil {
+			v.addError(path, "%s", err.Error())
+		}
+	case "links":
+		if !validLink.MatchString(item) {
+			v.addError(path, "Invalid link '%s', expected 'container-name[:alias]'", item)
+		}
+	case "ports":
+		if _, err := ParsePorts(item); err != nil {
+			v.addError(path, "%s", err.Error())
+		}
+	case "ignore_changes":
+		if _, err := filepath.Match(item, ""); err != nil || !strings.HasPrefix(item, "/") {
+			v.addError(path, "Invalid path '%s', expected an absolute path or pattern like '/tmp/**'", item)
+		}
+	case "cache_keys":
+		if _, err := filepath.Match(item, ""); err != nil || filepath.IsAbs(item) {
+			v.addError(path, "Invalid cache key '%s', expected a path or pattern relative to the project dir", item)
+		}
+	}
+}
+
+// Checks single values whose format we know about
+func (v *configValidator) validateValue(path []string, key, value string) {
+	switch key {
+	case "keep_newer_than":
+		if _, err := ParseRetentionAge(value); err != nil {
+			v.addError(path, "%s", err.Error())
+		}
+	case "registry":
+		if !validRegistry.MatchString(value) {
+			v.addError(path, "Invalid registry '%s', expected 'host[:port]'", value)
+		}
+	}
+}
+
+// Maps the yaml keys of a struct to its fields
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = field
+		}
+	}
+	return fields
+}
+
+// Looks for a known key that is close enough to an unknown one to be a typo
+func suggestKey(name string, fields map[string]reflect.StructField) string {
+	suggestion := ""
+	best := 3
+	for known := range fields {
+		if distance := editDistance(name, known); distance < best || (distance == best && known < suggestion) {
+			best = distance
+			suggestion = known
+		}
+	}
+	return suggestion
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
+
+var (
+	yamlKeyLine  = regexp.MustCompile(`^(\s*)(?:'([^']*)'|"([^"]*)"|([^\s'"#:][^:#]*?))\s*:(\s|$)`)
+	yamlItemLine = regexp.MustCompile(`^(\s*)-(\s|$)`)
+)
+
+type yamlLineEntry struct {
+	indent int
+	name   string
+	isItem bool
+}
+
+// Builds a map of key paths (like `hack.volumes[0]`) to the line they are
+// defined at. This is not a full blown yaml parser, it only understands
+// the block style used on devstep.yml files and entries written using the
+// flow style (like `[a, b]`) are reported at the line of their parents.
+func indexYamlLines(data string) map[string]int {
+	lines := make(map[string]int)
+	counters := make(map[string]int)
+	stack := []yamlLineEntry{}
+
+	for i, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+
+		if match := yamlItemLine.FindStringSubmatch(line); match != nil {
+			indent := len(match[1])
+			for len(stack) > 0 && (stack[len(stack)-1].indent > indent || (stack[len(stack)-1].indent == indent && stack[len(stack)-1].isItem)) {
+				stack = stack[:len(stack)-1]
+			}
+			parent := yamlPathFromEntries(stack)
+			name := "[" + strconv.Itoa(counters[parent]) + "]"
+			counters[parent]++
+			stack = append(stack, yamlLineEntry{indent: indent, name: name, isItem: true})
+			recordYamlLine(lines, stack, i+1)
+			continue
+		}
+
+		if match := yamlKeyLine.FindStringSubmatch(line); match != nil {
+			indent := len(match[1])
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			name := match[2] + match[3] + match[4]
+			stack = append(stack, yamlLineEntry{indent: indent, name: name})
+			recordYamlLine(lines, stack, i+1)
+		}
+	}
+
+	return lines
+}
+
+// Finds the line of the closest parent of the path that we know about
+func lookupYamlLine(lines map[string]int, path []string) int {
+	for i := len(path); i > 0; i-- {
+		if line, found := lines[yamlPath(path[:i])]; found {
+			return line
+		}
+	}
+	return 0
+}
+
+func recordYamlLine(lines map[string]int, stack []yamlLineEntry, line int) {
+	path := yamlPathFromEntries(stack)
+	if _, found := lines[path]; !found {
+		lines[path] = line
+	}
+}
+
+func yamlPathFromEntries(entries []yamlLineEntry) string {
+	path := []string{}
+	for _, entry := range entries {
+		path = append(path, entry.name)
+	}
+	return yamlPath(path)
+}
+
+func childPath(path []string, name string) []string {
+	return append(path[:len(path):len(path)], name)
+}
+
+func yamlPath(path []string) string {
+	result := ""
+	for _, name := range path {
+		if strings.HasPrefix(name, "[") || result == "" {
+			result += name
+		} else {
+			result += "." + name
+		}
+	}
+	return result
+}
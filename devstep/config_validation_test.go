@@ -0,0 +1,169 @@
+package devstep_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_ReportsUnknownKeysWithLineNumbers(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+source_image: 'source/image:tag'
+enviroment:
+  FOO: 'bar'
+hack:
+  privileged: true
+  services:
+    db:
+      image: 'postgres'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	_, err := loader.Load()
+
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, 2, len(errs))
+
+	equals(t, tempDir+"/devstep.yml", errs[0].File)
+	equals(t, 3, errs[0].Line)
+	equals(t, "Unknown key 'enviroment', did you mean 'environment'?", errs[0].Message)
+
+	equals(t, 7, errs[1].Line)
+	equals(t, "Unknown key 'services'", errs[1].Message)
+}
+
+func Test_ReportsMalformedValues(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+volumes:
+- '/host/dir:/guest/dir'
+- '/no/guest/dir'
+links:
+- 'invalid link'
+environment:
+  1INVALID: 'value'
+services:
+  db:
+    image: 'postgres'
+    ports:
+    - 'not-a-port'
+run:
+  privileged: 'yes'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	_, err := loader.Load()
+
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, 5, len(errs))
+
+	equals(t, 4, errs[0].Line)
+	equals(t, "Invalid volume '/no/guest/dir', expected 'host-dir:/guest-dir[:ro|rw]'", errs[0].Message)
+	equals(t, 6, errs[1].Line)
+	equals(t, "Invalid link 'invalid link', expected 'container-name[:alias]'", errs[1].Message)
+	equals(t, 8, errs[2].Line)
+	equals(t, "Invalid environment variable name '1INVALID'", errs[2].Message)
+	equals(t, 13, errs[3].Line)
+	equals(t, "Invalid port 'not-a-port', expected '[[ip:][host-port]:]container-port[/tcp|udp]'", errs[3].Message)
+	equals(t, 15, errs[4].Line)
+	equals(t, "Expected 'privileged' to be true or false", errs[4].Message)
+}
+
+func Test_ReportsErrorsFromAllConfigFiles(t *testing.T) {
+	tempHomeDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempHomeDir+"/devstep.yml", `
+repository: 'some/repo'
+profiles:
+  ci:
+    privileged: true
+`)
+	defer os.RemoveAll(tempHomeDir)
+	tempProjectDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempProjectDir+"/devstep.yml", `
+profiles:
+  ci:
+    profiles:
+      other: {}
+`)
+	defer os.RemoveAll(tempProjectDir)
+
+	loader, _ := newConfigLoader(tempHomeDir, tempProjectDir)
+	_, err := loader.Load()
+
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, 3, len(errs))
+
+	var homeErrs, projectErrs []*devstep.ConfigError
+	for _, e := range errs {
+		if e.File == tempHomeDir+"/devstep.yml" {
+			homeErrs = append(homeErrs, e)
+		} else {
+			projectErrs = append(projectErrs, e)
+		}
+	}
+
+	equals(t, 2, len(homeErrs))
+	equals(t, 2, homeErrs[0].Line)
+	equals(t, "Repository name can't be set globally", homeErrs[0].Message)
+	equals(t, 5, homeErrs[1].Line)
+	equals(t, "Privileged can't be set globally", homeErrs[1].Message)
+
+	equals(t, 1, len(projectErrs))
+	equals(t, 4, projectErrs[0].Line)
+	equals(t, "Profiles can't be nested", projectErrs[0].Message)
+}
+
+func Test_ReportsTemplateErrors(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+source_image: 'source/image:tag'
+repository: '{{ template "missing" }}'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	_, err := loader.Load()
+
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, 1, len(errs))
+	equals(t, 3, errs[0].Line)
+}
+
+func Test_ValidateDoesNotTalkToDocker(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+source_image: 'source/image:tag'
+profiles:
+  ci:
+    environment:
+      CI: 'true'
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, client := newConfigLoaderWithProfile("", tempDir, "ci")
+	client.ListTagsFunc = func(string) ([]string, error) {
+		return nil, errors.New("Docker is not available")
+	}
+
+	ok(t, loader.Validate())
+
+	loader, _ = newConfigLoaderWithProfile("", tempDir, "missing")
+	assert(t, loader.Validate() != nil, "Unknown profile was accepted")
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "nil"
+	}
+	return err.Error()
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 )
 
+const Version = "1.0.0"
+
 var log *logPkg.Logger
 var LogLevel string
 
@@ -1,68 +1,164 @@
 package devstep
 
-// TODO: Figure out how this can be unit tested
-
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"github.com/fgrehm/go-dockerpty"
 	"github.com/fsouza/go-dockerclient"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type DockerClient interface {
-	Execute(*DockerExecOpts) error
+	Execute(*DockerExecOpts) (*DockerExecResult, error)
 	Run(*DockerRunOpts) (*DockerRunResult, error)
 	RemoveContainer(string) error
-	ContainerChanged(string) (bool, error)
-	ContainerHasExecInstancesRunning(string) bool
+	ContainerChanges(string) ([]*DockerChange, error)
+	ImageContents(string) (*DockerContents, error)
+	ContainerContents(string) (*DockerContents, error)
+	ContainerHasExecInstancesRunning(string) (bool, error)
 	Commit(*DockerCommitOpts) error
 	RemoveImage(string) error
+	InspectImage(string) (*DockerImage, error)
+	TagImage(string, string, string) error
+	SaveImage(string, io.Writer) error
+	LoadImage(io.Reader) error
+	PushImage(string, string) error
+	PullImage(string, string) error
 	ListTags(string) ([]string, error)
-	ListContainers(string) ([]string, error)
+	ListContainers(map[string]string) ([]string, error)
+	FindContainers(map[string]string) ([]*DockerContainer, error)
 	LookupContainerID(string) (string, error)
+	ContainerStatus(string) (*DockerContainerStatus, error)
+	StartContainer(string) error
+	StopContainer(string) error
 }
 
 type DockerExecOpts struct {
 	ContainerID string
 	User        string
 	Cmd         []string
+	NoTTY       bool // stream stdout and stderr separately instead of using a pseudo terminal
+}
+
+type DockerExecResult struct {
+	ExecID   string
+	ExitCode int
 }
 
 type DockerRunResult struct {
 	ContainerID string
 	ExitCode    int
+	Ports       []PortMapping // ports bound on the host, only known for detached containers
+}
+
+type DockerContainerStatus struct {
+	ContainerID string
+	Image       string
+	Running     bool
+}
+
+// A container as listed by FindContainers
+type DockerContainer struct {
+	ID           string
+	Name         string
+	Image        string
+	Labels       map[string]string
+	Created      time.Time
+	Running      bool
+	Status       string        // as reported by Docker, like "Up 5 minutes"
+	Ports        []PortMapping // ports bound on the host
+	ExecSessions int           // number of exec instances running
 }
 
 type DockerCommitOpts struct {
 	ContainerID    string
 	RepositoryName string
 	Tag            string
+	Message        string
+	Author         string
+	Pause          *bool // nil uses Docker's default, which pauses the container
+	Labels         map[string]string
+}
+
+type DockerImage struct {
+	ID      string
+	Created time.Time
+	Size    int64
+	Labels  map[string]string
+}
+
+// Kinds of changes made to the filesystem of a container, using the same
+// notation as `docker diff`
+const (
+	ChangeModified = "C"
+	ChangeAdded    = "A"
+	ChangeDeleted  = "D"
+)
+
+// A change made to the filesystem of a container
+type DockerChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"` // ChangeModified, ChangeAdded or ChangeDeleted
+}
+
+func (c *DockerChange) String() string {
+	return c.Kind + " " + c.Path
 }
 
 type dockerClient struct {
 	client *docker.Client
 }
 
-func (c *dockerClient) Execute(opts *DockerExecOpts) error {
+func (c *dockerClient) Execute(opts *DockerExecOpts) (*DockerExecResult, error) {
 	log.Info("Creating exec instance")
 	log.Debug("%+v", opts)
 
 	exec, err := c.client.CreateExec(docker.CreateExecOptions{
 		Container:    opts.ContainerID,
 		User:         opts.User,
-		AttachStdin:  true,
+		AttachStdin:  !opts.NoTTY,
 		AttachStdout: true,
 		AttachStderr: true,
-		Tty:          true,
+		Tty:          !opts.NoTTY,
 		Cmd:          opts.Cmd,
 	})
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if opts.NoTTY {
+		log.Info("Starting Exec instance without pseudo terminal")
+		err = c.client.StartExec(exec.ID, docker.StartExecOptions{
+			OutputStream: os.Stdout,
+			ErrorStream:  os.Stderr,
+		})
+	} else {
+		log.Info("Starting Exec instance with pseudo terminal")
+		err = dockerpty.StartExec(c.client, exec)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	log.Info("Starting Exec instance with pseudo terminal")
-	return dockerpty.StartExec(c.client, exec)
+	execInfo, err := c.client.InspectExec(exec.ID)
+	if err != nil {
+		return nil, errors.New("Error inspecting exec instance:\n  " + err.Error())
+	}
+
+	return &DockerExecResult{ExecID: exec.ID, ExitCode: execInfo.ExitCode}, nil
 }
 
 func (c *dockerClient) Run(opts *DockerRunOpts) (*DockerRunResult, error) {
@@ -74,7 +170,8 @@ func (c *dockerClient) Run(opts *DockerRunOpts) (*DockerRunResult, error) {
 	if err != nil {
 		return nil, errors.New("Error creating container: \n  " + err.Error())
 	}
-	log.Info("Container created (ID='%s')", container.ID)
+	containerID := container.ID
+	log.Info("Container created (ID='%s')", containerID)
 
 	if opts.AutoRemove {
 		defer c.RemoveContainer(container.ID)
@@ -85,29 +182,164 @@ func (c *dockerClient) Run(opts *DockerRunOpts) (*DockerRunResult, error) {
 
 	if opts.Detach {
 		err = c.client.StartContainer(container.ID, hostConfig)
-	} else if opts.Pty {
-		log.Info("Starting container with pseudo terminal")
-		err = dockerpty.Start(c.client, container, hostConfig)
 	} else {
-		return nil, errors.New("Starting daemon containers without Pty was not needed until this moment, please implement :)")
+		stopForwarding := c.forwardSignals(container.ID)
+		if opts.Pty {
+			log.Info("Starting container with pseudo terminal")
+			stopWatching := c.watchStart(container.ID, opts)
+			err = dockerpty.Start(c.client, container, hostConfig)
+			stopWatching()
+		} else {
+			log.Info("Starting container without pseudo terminal")
+			err = c.startAndWait(container.ID, opts, hostConfig)
+		}
+		if sig := stopForwarding(); sig != nil && err == nil {
+			err = &InterruptedError{Signal: sig}
+		}
 	}
 
-	if err != nil {
-		return nil, errors.New("Error starting container:\n  " + err.Error())
+	// The container ID is returned along with errors so that callers can clean
+	// things up
+	if interrupted, ok := err.(*InterruptedError); ok {
+		return &DockerRunResult{ContainerID: container.ID, ExitCode: -1}, interrupted
+	} else if err != nil {
+		return &DockerRunResult{ContainerID: container.ID, ExitCode: -1}, errors.New("Error starting container:\n  " + err.Error())
 	}
 
 	container, err = c.client.InspectContainer(container.ID)
 	if err != nil {
-		return nil, errors.New("Error inspecting container:\n  " + err.Error())
+		return &DockerRunResult{ContainerID: containerID, ExitCode: -1}, errors.New("Error inspecting container:\n  " + err.Error())
 	}
 	result := &DockerRunResult{
 		ContainerID: container.ID,
 		ExitCode:    container.State.ExitCode,
+		Ports:       boundPorts(container.NetworkSettings),
 	}
 
 	return result, nil
 }
 
+// How long containers have to exit after a signal gets forwarded to them
+// before they get killed
+var StopTimeout = 10 * time.Second
+
+// Forwards SIGINT / SIGTERM received by devstep to the container while it
+// runs, killing it if it does not exit within the StopTimeout or if another
+// signal is received. The returned function stops forwarding and returns the
+// first signal received.
+func (c *dockerClient) forwardSignals(containerID string) func() os.Signal {
+	var mu sync.Mutex
+	var killTimer *time.Timer
+
+	kill := func(signal docker.Signal) {
+		err := c.client.KillContainer(docker.KillContainerOptions{ID: containerID, Signal: signal})
+		if err != nil {
+			log.Debug("Error sending signal to container '%s': %s", containerID, err)
+		}
+	}
+
+	stop := handleSignals(func(sig os.Signal) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if killTimer != nil {
+			log.Info("Received %s again, killing container '%s'", sig, containerID)
+			killTimer.Stop()
+			kill(docker.SIGKILL)
+			return
+		}
+
+		log.Info("Forwarding %s to container '%s'", sig, containerID)
+		if sysSignal, ok := sig.(syscall.Signal); ok {
+			kill(docker.Signal(sysSignal))
+		}
+		killTimer = time.AfterFunc(StopTimeout, func() {
+			log.Info("Container '%s' did not exit after %s, killing it", containerID, StopTimeout)
+			kill(docker.SIGKILL)
+		})
+	})
+
+	return func() os.Signal {
+		sig := stop()
+		mu.Lock()
+		defer mu.Unlock()
+		if killTimer != nil {
+			killTimer.Stop()
+		}
+		return sig
+	}
+}
+
+// Attaches to the container stdout and stderr before starting it so that no
+// output gets lost and waits for the container to finish
+func (c *dockerClient) startAndWait(containerID string, opts *DockerRunOpts, hostConfig *docker.HostConfig) error {
+	success := make(chan struct{})
+	attachOpts := opts.toAttachOpts(containerID)
+	attachOpts.Success = success
+
+	attachment, err := c.client.AttachToContainerNonBlocking(attachOpts)
+	if err != nil {
+		return err
+	}
+	<-success
+	success <- struct{}{}
+
+	if err = c.client.StartContainer(containerID, hostConfig); err != nil {
+		attachment.Close()
+		return err
+	}
+	if opts.OnStart != nil {
+		c.notifyStart(containerID, opts)
+	}
+	if _, err = c.client.WaitContainer(containerID); err != nil {
+		attachment.Close()
+		return err
+	}
+	return attachment.Wait()
+}
+
+// Containers attached to a pseudo terminal are started by dockerpty, which
+// blocks until they exit, so we poll for them to be running in the
+// background. The returned function stops polling.
+func (c *dockerClient) watchStart(containerID string, opts *DockerRunOpts) func() {
+	if opts.OnStart == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for !c.notifyStart(containerID, opts) {
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// Calls OnStart with the ports bound if the container is running, returns
+// whether it was called
+func (c *dockerClient) notifyStart(containerID string, opts *DockerRunOpts) bool {
+	container, err := c.client.InspectContainer(containerID)
+	if err != nil {
+		log.Debug("Error inspecting container '%s': %s", containerID, err)
+		return false
+	}
+	if !container.State.Running {
+		return false
+	}
+	opts.OnStart(&DockerRunResult{ContainerID: containerID, Ports: boundPorts(container.NetworkSettings)})
+	return true
+}
+
 func (c *dockerClient) RemoveContainer(containerID string) error {
 	log.Info("Removing container '%s'", containerID)
 	return c.client.RemoveContainer(docker.RemoveContainerOptions{
@@ -117,51 +349,155 @@ func (c *dockerClient) RemoveContainer(containerID string) error {
 	})
 }
 
-func (c *dockerClient) ContainerChanged(containerID string) (bool, error) {
+// Lists the changes made to the filesystem of a container
+func (c *dockerClient) ContainerChanges(containerID string) ([]*DockerChange, error) {
 	changes, err := c.client.ContainerChanges(containerID)
-	log.Debug("Container changes '%v'", changes)
-
-	if len(changes) == 0 {
-		return false, err
+	if err != nil {
+		return nil, errors.New("Error listing container changes:\n  " + err.Error())
 	}
+	log.Debug("Container changes '%v'", changes)
 
-	blankChanges := []docker.Change{
-		docker.Change{
-			Path: "/home",
-			Kind: docker.ChangeModify,
-		},
-		docker.Change{
-			Path: "/home/devstep",
-			Kind: docker.ChangeModify,
-		},
-		docker.Change{
-			Path: "/home/devstep/.rnd",
-			Kind: docker.ChangeModify,
-		},
-	}
-	for i, v := range blankChanges {
-		if v != changes[i] {
-			return true, err
+	result := []*DockerChange{}
+	for _, change := range changes {
+		kind := ChangeModified
+		switch change.Kind {
+		case docker.ChangeAdd:
+			kind = ChangeAdded
+		case docker.ChangeDelete:
+			kind = ChangeDeleted
 		}
+		result = append(result, &DockerChange{Path: change.Path, Kind: kind})
 	}
-	return len(changes) > 3, err
+	return result, nil
 }
 
 func (c *dockerClient) Commit(opts *DockerCommitOpts) error {
+	if opts.Pause != nil && !*opts.Pause {
+		return c.commitWithoutPausing(opts)
+	}
+
 	_, err := c.client.CommitContainer(docker.CommitContainerOptions{
 		Container:  opts.ContainerID,
 		Repository: opts.RepositoryName,
 		Tag:        opts.Tag,
+		Message:    opts.Message,
+		Author:     opts.Author,
+		Run:        &docker.Config{Labels: opts.Labels},
 	})
 
 	return err
 }
 
+// The API client we use does not support the `pause` parameter, so these
+// commits are sent to the API directly
+func (c *dockerClient) commitWithoutPausing(opts *DockerCommitOpts) error {
+	query := url.Values{}
+	query.Set("container", opts.ContainerID)
+	query.Set("repo", opts.RepositoryName)
+	query.Set("tag", opts.Tag)
+	query.Set("comment", opts.Message)
+	query.Set("author", opts.Author)
+	query.Set("pause", "0")
+
+	body, err := json.Marshal(&docker.Config{Labels: opts.Labels})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.post("/commit?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return errors.New("Error commiting container:\n  " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return errors.New("Error commiting container:\n  " + strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Sends a JSON request to the endpoint the API client is connected to
+func (c *dockerClient) post(path string, body io.Reader) (*http.Response, error) {
+	endpoint, err := url.Parse(c.client.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.client.HTTPClient
+	switch endpoint.Scheme {
+	case "unix":
+		socket := endpoint.Path
+		httpClient = &http.Client{Transport: &http.Transport{
+			Dial: func(string, string) (net.Conn, error) {
+				return net.Dial("unix", socket)
+			},
+		}}
+		endpoint = &url.URL{Scheme: "http", Host: "docker.sock"}
+	case "tcp":
+		endpoint.Scheme = "http"
+		if c.client.TLSConfig != nil {
+			endpoint.Scheme = "https"
+		}
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(endpoint.String(), "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return httpClient.Do(req)
+}
+
 func (c *dockerClient) RemoveImage(name string) error {
 	log.Info("Removing image '%s'", name)
 	return c.client.RemoveImage(name)
 }
 
+// Returns nil if the image does not exist
+func (c *dockerClient) InspectImage(name string) (*DockerImage, error) {
+	image, err := c.client.InspectImage(name)
+	if err == docker.ErrNoSuchImage {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("Error inspecting image:\n  " + err.Error())
+	}
+	result := &DockerImage{ID: image.ID, Created: image.Created, Size: image.Size}
+	if image.Config != nil {
+		result.Labels = image.Config.Labels
+	}
+	return result, nil
+}
+
+// Tags an image, moving the tag in case it is already in use
+func (c *dockerClient) TagImage(name, repositoryName, tag string) error {
+	log.Info("Tagging '%s' as '%s:%s'", name, repositoryName, tag)
+	return c.client.TagImage(name, docker.TagImageOptions{
+		Repo:  repositoryName,
+		Tag:   tag,
+		Force: true,
+	})
+}
+
+// Writes an image to a tar archive in the format used by `docker save`
+func (c *dockerClient) SaveImage(name string, output io.Writer) error {
+	log.Info("Saving image '%s'", name)
+	err := c.client.ExportImage(docker.ExportImageOptions{Name: name, OutputStream: output})
+	if err != nil {
+		return errors.New("Error saving image:\n  " + err.Error())
+	}
+	return nil
+}
+
+// Loads the images from an archive created by SaveImage (or `docker save`)
+func (c *dockerClient) LoadImage(input io.Reader) error {
+	log.Info("Loading image")
+	if err := c.client.LoadImage(docker.LoadImageOptions{InputStream: input}); err != nil {
+		return errors.New("Error loading image:\n  " + err.Error())
+	}
+	return nil
+}
+
 // List tags for a given repository
 func (c *dockerClient) ListTags(repositoryName string) ([]string, error) {
 	if repositoryName == "" {
@@ -187,42 +523,56 @@ func (c *dockerClient) ListTags(repositoryName string) ([]string, error) {
 	return tags, err
 }
 
-func (c *dockerClient) ContainerHasExecInstancesRunning(containerID string) bool {
+// Containers that don't exist are considered to have no exec instances
+func (c *dockerClient) ContainerHasExecInstancesRunning(containerID string) (bool, error) {
+	running, err := c.runningExecs(containerID)
+	if _, notFound := err.(*docker.NoSuchContainer); notFound {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("Error inspecting exec instances:\n  " + err.Error())
+	}
+	return running > 0, nil
+}
+
+// Counts the exec instances running on a container
+func (c *dockerClient) runningExecs(containerID string) (int, error) {
 	container, err := c.client.InspectContainer(containerID)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 	log.Debug("ExecIDs %+v", container.ExecIDs)
+	running := 0
 	for _, execID := range container.ExecIDs {
 		execInspect, err := c.client.InspectExec(execID)
 		if err != nil {
-			panic(err)
+			return 0, err
 		}
 		log.Debug("execInspect ID=%s RUNNING=%+v", execInspect.ID, execInspect.Running)
 		if execInspect.Running {
-			return true
+			running++
 		}
 	}
-	return false
+	return running, nil
 }
 
-// List Containers for a given image
-func (c *dockerClient) ListContainers(image string) ([]string, error) {
-	if image == "" {
-		return nil, errors.New("Image name can't be blank")
+// List the IDs of running containers that have the provided labels
+func (c *dockerClient) ListContainers(labels map[string]string) ([]string, error) {
+	if len(labels) == 0 {
+		return nil, errors.New("Labels can't be blank")
 	}
 
-	log.Info("Fetching containers for '%s'", image)
+	log.Info("Fetching running containers labeled with %v", labels)
 
 	containers, err := c.client.ListContainers(docker.ListContainersOptions{
-		Filters: map[string][]string{"status": []string{"running"}},
+		Filters: map[string][]string{
+			"status": []string{"running"},
+			"label":  labelFilters(labels),
+		},
 	})
 	containerIds := []string{}
 	for _, container := range containers {
 		log.Debug("Found '%+v'", container)
-		if container.Image == image {
-			containerIds = append(containerIds, container.ID)
-		}
+		containerIds = append(containerIds, container.ID)
 	}
 
 	log.Info("Containers found %v", containerIds)
@@ -230,6 +580,55 @@ func (c *dockerClient) ListContainers(image string) ([]string, error) {
 	return containerIds, err
 }
 
+// Lists all containers (running or not) that have the provided labels, blank
+// values match any value
+func (c *dockerClient) FindContainers(labels map[string]string) ([]*DockerContainer, error) {
+	log.Info("Fetching containers labeled with %v", labels)
+	apiContainers, err := c.client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": labelFilters(labels)},
+	})
+	if err != nil {
+		return nil, errors.New("Error listing containers:\n  " + err.Error())
+	}
+
+	containers := []*DockerContainer{}
+	for _, apiContainer := range apiContainers {
+		container := &DockerContainer{
+			ID:      apiContainer.ID,
+			Image:   apiContainer.Image,
+			Labels:  apiContainer.Labels,
+			Created: time.Unix(apiContainer.Created, 0),
+			Running: apiContainer.State == "running" || strings.HasPrefix(apiContainer.Status, "Up"),
+			Status:  apiContainer.Status,
+		}
+		if len(apiContainer.Names) > 0 {
+			container.Name = strings.TrimPrefix(apiContainer.Names[0], "/")
+		}
+		for _, port := range apiContainer.Ports {
+			if port.PublicPort == 0 {
+				continue
+			}
+			container.Ports = append(container.Ports, PortMapping{
+				HostIP:        port.IP,
+				HostPort:      strconv.FormatInt(port.PublicPort, 10),
+				ContainerPort: strconv.FormatInt(port.PrivatePort, 10),
+				Protocol:      port.Type,
+			})
+		}
+		sort.Sort(portMappings(container.Ports))
+
+		if container.Running {
+			if container.ExecSessions, err = c.runningExecs(container.ID); err != nil {
+				return nil, errors.New("Error inspecting container:\n  " + err.Error())
+			}
+		}
+		containers = append(containers, container)
+	}
+
+	return containers, nil
+}
+
 func (c *dockerClient) LookupContainerID(containerName string) (string, error) {
 	container, err := c.client.InspectContainer(containerName)
 	if err != nil {
@@ -238,8 +637,92 @@ func (c *dockerClient) LookupContainerID(containerName string) (string, error) {
 	return container.Name, nil
 }
 
+// Returns nil if the container does not exist
+func (c *dockerClient) ContainerStatus(containerName string) (*DockerContainerStatus, error) {
+	container, err := c.client.InspectContainer(containerName)
+	if err != nil {
+		if _, notFound := err.(*docker.NoSuchContainer); notFound {
+			return nil, nil
+		}
+		return nil, errors.New("Error inspecting container:\n  " + err.Error())
+	}
+
+	status := &DockerContainerStatus{
+		ContainerID: container.ID,
+		Running:     container.State.Running,
+	}
+	if container.Config != nil {
+		status.Image = container.Config.Image
+	}
+	return status, nil
+}
+
+// Starts a container that has already been created
+func (c *dockerClient) StartContainer(containerID string) error {
+	log.Info("Starting container '%s'", containerID)
+	if err := c.client.StartContainer(containerID, nil); err != nil {
+		return errors.New("Error starting container:\n  " + err.Error())
+	}
+	return nil
+}
+
+func (c *dockerClient) StopContainer(containerID string) error {
+	log.Info("Stopping container '%s'", containerID)
+	err := c.client.StopContainer(containerID, 10)
+	if _, notRunning := err.(*docker.ContainerNotRunning); notRunning {
+		return nil
+	}
+	return err
+}
+
 func NewClient() DockerClient {
 	// TODO: Error handling
 	innerClient, _ := docker.NewClientFromEnv()
 	return &dockerClient{innerClient}
 }
+
+// Label filters for the Docker API, blank values match any value
+func labelFilters(labels map[string]string) []string {
+	filters := []string{}
+	for _, k := range sortedKeys(labels) {
+		if labels[k] == "" {
+			filters = append(filters, k)
+		} else {
+			filters = append(filters, k+"="+labels[k])
+		}
+	}
+	return filters
+}
+
+func boundPorts(settings *docker.NetworkSettings) []PortMapping {
+	if settings == nil {
+		return nil
+	}
+
+	ports := []PortMapping{}
+	for port, bindings := range settings.Ports {
+		for _, binding := range bindings {
+			ports = append(ports, PortMapping{
+				HostIP:        binding.HostIP,
+				HostPort:      binding.HostPort,
+				ContainerPort: port.Port(),
+				Protocol:      port.Proto(),
+			})
+		}
+	}
+	sort.Sort(portMappings(ports))
+	return ports
+}
+
+type portMappings []PortMapping
+
+func (p portMappings) Len() int      { return len(p) }
+func (p portMappings) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p portMappings) Less(i, j int) bool {
+	if p[i].ContainerPort != p[j].ContainerPort {
+		a, _ := strconv.Atoi(p[i].ContainerPort)
+		b, _ := strconv.Atoi(p[j].ContainerPort)
+		return a < b
+	}
+	return p[i].String() < p[j].String()
+}
@@ -1 +1,617 @@
 package devstep_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+	"github.com/fgrehm/devstep-cli/devstep/dockertest"
+)
+
+func Test_DockerClientListTags(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("devstep/project:latest", "devstep/project:20160101000000")
+	server.AddImage("devstep/other-project:latest")
+
+	tags, err := client.ListTags("devstep/project")
+	ok(t, err)
+	equals(t, []string{"latest", "20160101000000"}, tags)
+
+	tags, err = client.ListTags("devstep/unknown")
+	ok(t, err)
+	equals(t, []string{}, tags)
+
+	_, err = client.ListTags("")
+	assert(t, err != nil, "Blank repository name was allowed")
+}
+
+func Test_DockerClientContainerChanges(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	container := server.AddContainer("a-container", "some/image")
+
+	changes, err := client.ContainerChanges(container.ID)
+	ok(t, err)
+	equals(t, []*devstep.DockerChange{}, changes)
+
+	container.Changes = []dockertest.Change{
+		{Path: "/home/devstep", Kind: dockertest.ChangeModify},
+		{Path: "/home/devstep/.bashrc", Kind: dockertest.ChangeAdd},
+		{Path: "/tmp/build.log", Kind: dockertest.ChangeDelete},
+	}
+	changes, err = client.ContainerChanges(container.ID)
+	ok(t, err)
+	equals(t, []*devstep.DockerChange{
+		{Path: "/home/devstep", Kind: devstep.ChangeModified},
+		{Path: "/home/devstep/.bashrc", Kind: devstep.ChangeAdded},
+		{Path: "/tmp/build.log", Kind: devstep.ChangeDeleted},
+	}, changes)
+
+	_, err = client.ContainerChanges("unknown")
+	assert(t, err != nil, "Error for unknown container was swallowed")
+}
+
+func Test_DockerClientContainerHasExecInstancesRunning(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	container := server.AddContainer("a-container", "some/image")
+	running, err := client.ContainerHasExecInstancesRunning(container.ID)
+	ok(t, err)
+	assert(t, !running, "Container without execs has exec instances running")
+
+	server.AddExec(container.ID, false)
+	running, err = client.ContainerHasExecInstancesRunning(container.ID)
+	ok(t, err)
+	assert(t, !running, "Finished exec was considered running")
+
+	server.AddExec(container.ID, true)
+	running, err = client.ContainerHasExecInstancesRunning(container.ID)
+	ok(t, err)
+	assert(t, running, "Running exec was not detected")
+
+	running, err = client.ContainerHasExecInstancesRunning("unknown")
+	ok(t, err)
+	assert(t, !running, "Unknown container has exec instances running")
+}
+
+func Test_DockerClientRunDetached(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("some/image:tag")
+	server.OnStart = func(c *dockertest.Container) {
+		c.Running = true
+	}
+
+	result, err := client.Run(&devstep.DockerRunOpts{
+		Name:    "a-container",
+		Image:   "some/image:tag",
+		Detach:  true,
+		Cmd:     []string{"--"},
+		Workdir: "/workspace",
+		Env:     map[string]string{"FOO": "bar"},
+		Volumes: []string{"/host:/workspace"},
+		Links:   []string{"db:db"},
+		Publish: []string{"8080:80"},
+	})
+	ok(t, err)
+
+	container := server.Container("a-container")
+	assert(t, container != nil, "Container was not created")
+	equals(t, container.ID, result.ContainerID)
+	assert(t, container.Running, "Container is not running")
+	equals(t, "some/image:tag", container.Config.Image)
+	equals(t, []string{"--"}, container.Config.Cmd)
+	equals(t, "/workspace", container.Config.WorkingDir)
+	equals(t, []string{"FOO=bar"}, container.Config.Env)
+	equals(t, []string{"/host:/workspace"}, container.HostConfig.Binds)
+	equals(t, []string{"db:db"}, container.HostConfig.Links)
+	equals(t, []dockertest.PortBinding{{HostPort: "8080"}}, container.HostConfig.PortBindings["80/tcp"])
+}
+
+func Test_DockerClientRunReportsBoundPorts(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("some/image:tag")
+	server.OnStart = func(c *dockertest.Container) {
+		c.Running = true
+	}
+
+	result, err := client.Run(&devstep.DockerRunOpts{
+		Name:    "a-container",
+		Image:   "some/image:tag",
+		Detach:  true,
+		Publish: []string{"127.0.0.1:8080:80", "53/udp", "9000-9001:3000-3001"},
+	})
+	ok(t, err)
+
+	container := server.Container("a-container")
+	_, exposed := container.Config.ExposedPorts["53/udp"]
+	assert(t, exposed, "UDP port was not exposed")
+	equals(t, []dockertest.PortBinding{{HostIP: "127.0.0.1", HostPort: "8080"}}, container.HostConfig.PortBindings["80/tcp"])
+	equals(t, []dockertest.PortBinding{{HostPort: "9001"}}, container.HostConfig.PortBindings["3001/tcp"])
+
+	equals(t, []devstep.PortMapping{
+		{HostIP: "0.0.0.0", HostPort: "32768", ContainerPort: "53", Protocol: "udp"},
+		{HostIP: "127.0.0.1", HostPort: "8080", ContainerPort: "80", Protocol: "tcp"},
+		{HostIP: "0.0.0.0", HostPort: "9000", ContainerPort: "3000", Protocol: "tcp"},
+		{HostIP: "0.0.0.0", HostPort: "9001", ContainerPort: "3001", Protocol: "tcp"},
+	}, result.Ports)
+}
+
+func Test_DockerClientRunReportsPortsOnceAttachedContainersStart(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("some/image:tag")
+	server.OnStart = func(c *dockertest.Container) {
+		c.Running = true
+	}
+
+	var started *devstep.DockerRunResult
+	result, err := client.Run(&devstep.DockerRunOpts{
+		Image:   "some/image:tag",
+		Publish: []string{"80"},
+		OnStart: func(result *devstep.DockerRunResult) {
+			started = result
+			client.StopContainer(result.ContainerID)
+		},
+	})
+	ok(t, err)
+
+	assert(t, started != nil, "OnStart was not called")
+	equals(t, result.ContainerID, started.ContainerID)
+	equals(t, []devstep.PortMapping{{HostIP: "0.0.0.0", HostPort: "32768", ContainerPort: "80", Protocol: "tcp"}}, started.Ports)
+	equals(t, []devstep.PortMapping{}, result.Ports)
+}
+
+func Test_DockerClientRunReportsExitCode(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("some/image:tag")
+	server.OnStart = func(c *dockertest.Container) {
+		c.ExitCode = 3
+	}
+
+	result, err := client.Run(&devstep.DockerRunOpts{Image: "some/image:tag", Detach: true, AutoRemove: true})
+	ok(t, err)
+
+	equals(t, 3, result.ExitCode)
+	equals(t, 0, len(server.Containers()))
+}
+
+func Test_DockerClientRunWithoutPty(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("some/image:tag")
+	server.OnStart = func(c *dockertest.Container) {
+		c.Stdout = "some output\n"
+		c.Stderr = "some error\n"
+		c.ExitCode = 2
+	}
+
+	var result *devstep.DockerRunResult
+	var err error
+	stdout, stderr := captureOutput(func() {
+		result, err = client.Run(&devstep.DockerRunOpts{Image: "some/image:tag", Cmd: []string{"make"}, AutoRemove: true})
+	})
+	ok(t, err)
+
+	equals(t, 2, result.ExitCode)
+	equals(t, "some output\n", stdout)
+	equals(t, "some error\n", stderr)
+	equals(t, 0, len(server.Containers()))
+}
+
+func Test_DockerClientRunForwardsSignals(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	notified := make(chan chan<- os.Signal, 1)
+	defer devstep.UseSignalNotifier(func(signals chan<- os.Signal) func() {
+		notified <- signals
+		return func() {}
+	})()
+
+	server.AddImage("some/image:tag")
+	var containerID string
+	server.OnStart = func(c *dockertest.Container) {
+		containerID = c.ID
+		c.Running = true
+		(<-notified) <- syscall.SIGTERM
+	}
+
+	var result *devstep.DockerRunResult
+	var err error
+	captureOutput(func() {
+		result, err = client.Run(&devstep.DockerRunOpts{Image: "some/image:tag", Cmd: []string{"sleep", "100"}})
+	})
+
+	interrupted, isInterrupted := err.(*devstep.InterruptedError)
+	assert(t, isInterrupted, "Expected an InterruptedError")
+	equals(t, syscall.SIGTERM, interrupted.Signal)
+	equals(t, containerID, result.ContainerID)
+
+	container := server.Container(containerID)
+	assert(t, !container.Running, "Container is still running")
+	equals(t, []int{int(syscall.SIGTERM)}, container.Signals)
+}
+
+func Test_DockerClientExecuteWithoutTTY(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	container := server.AddContainer("a-container", "some/image")
+	server.OnExec = func(e *dockertest.Exec) {
+		e.Stdout = "exec output\n"
+		e.Stderr = "exec error\n"
+		e.ExitCode = 5
+	}
+
+	var result *devstep.DockerExecResult
+	var err error
+	stdout, stderr := captureOutput(func() {
+		result, err = client.Execute(&devstep.DockerExecOpts{
+			ContainerID: container.ID,
+			User:        "developer",
+			Cmd:         []string{"make", "test"},
+			NoTTY:       true,
+		})
+	})
+	ok(t, err)
+
+	equals(t, 5, result.ExitCode)
+	equals(t, "exec output\n", stdout)
+	equals(t, "exec error\n", stderr)
+
+	exec := server.Exec(result.ExecID)
+	assert(t, !exec.Tty, "Exec was created with a TTY")
+	equals(t, []string{"make", "test"}, exec.Cmd)
+	equals(t, "developer", exec.User)
+}
+
+func Test_DockerClientRunWithUnknownImage(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	_, err := client.Run(&devstep.DockerRunOpts{Image: "unknown/image", Detach: true})
+	assert(t, err != nil, "No error raised")
+	equals(t, 0, len(server.Containers()))
+}
+
+func Test_DockerClientCommit(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	container := server.AddContainer("a-container", "some/image")
+
+	err := client.Commit(&devstep.DockerCommitOpts{
+		ContainerID:    container.ID,
+		RepositoryName: "devstep/project",
+		Tag:            "latest",
+		Message:        "A message",
+		Author:         "Someone <someone@example.com>",
+		Labels:         map[string]string{"io.devstep.project": "/project"},
+	})
+	ok(t, err)
+
+	image := server.Image("devstep/project:latest")
+	assert(t, image != nil, "Image was not commited")
+	equals(t, container.ID, image.Container)
+	equals(t, "A message", image.Comment)
+	equals(t, "Someone <someone@example.com>", image.Author)
+	equals(t, "/project", image.Config.Labels["io.devstep.project"])
+	assert(t, image.Paused, "Container was not paused")
+
+	pause := false
+	err = client.Commit(&devstep.DockerCommitOpts{
+		ContainerID:    container.ID,
+		RepositoryName: "devstep/project",
+		Tag:            "unpaused",
+		Message:        "Another message",
+		Labels:         map[string]string{"io.devstep.project": "/project"},
+		Pause:          &pause,
+	})
+	ok(t, err)
+
+	image = server.Image("devstep/project:unpaused")
+	assert(t, image != nil, "Image was not commited")
+	assert(t, !image.Paused, "Container was paused")
+	equals(t, "Another message", image.Comment)
+	equals(t, "/project", image.Config.Labels["io.devstep.project"])
+
+	err = client.Commit(&devstep.DockerCommitOpts{ContainerID: "unknown", RepositoryName: "devstep/project", Tag: "latest", Pause: &pause})
+	assert(t, err != nil, "No error raised")
+
+	err = client.Commit(&devstep.DockerCommitOpts{ContainerID: "unknown", RepositoryName: "devstep/project", Tag: "latest"})
+	assert(t, err != nil, "No error raised")
+}
+
+func Test_DockerClientRemoveImage(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("devstep/project:latest", "devstep/project:20160101000000")
+
+	ok(t, client.RemoveImage("devstep/project:20160101000000"))
+
+	tags, err := client.ListTags("devstep/project")
+	ok(t, err)
+	equals(t, []string{"latest"}, tags)
+}
+
+func Test_DockerClientInspectImage(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	img := server.AddImage("devstep/project:latest", "devstep/project:20160101000000")
+	img.Size = 1024
+	img.Config.Labels = map[string]string{"io.devstep.dependencies-hash": "abc"}
+
+	image, err := client.InspectImage("devstep/project:20160101000000")
+	ok(t, err)
+	equals(t, img.ID, image.ID)
+	equals(t, int64(1024), image.Size)
+	equals(t, "abc", image.Labels["io.devstep.dependencies-hash"])
+
+	image, err = client.InspectImage("devstep/project:unknown")
+	ok(t, err)
+	assert(t, image == nil, "Image returned for unknown tag")
+}
+
+func Test_DockerClientTagImage(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	server.AddImage("devstep/project:latest")
+	old := server.AddImage("devstep/project:20160101000000")
+
+	ok(t, client.TagImage("devstep/project:20160101000000", "devstep/project", "latest"))
+
+	equals(t, old.ID, server.Image("devstep/project:latest").ID)
+	tags, err := client.ListTags("devstep/project")
+	ok(t, err)
+	equals(t, 2, len(tags))
+}
+
+func Test_DockerClientFindContainers(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	hack := server.AddContainer("project-hack", "devstep/project:latest")
+	hack.Config.Labels = map[string]string{"io.devstep.project": "/project", "io.devstep.role": "hack"}
+	hack.Ports = map[string][]dockertest.PortBinding{"80/tcp": {{HostIP: "0.0.0.0", HostPort: "8080"}}}
+	server.AddExec(hack.ID, true)
+	server.AddExec(hack.ID, false)
+
+	build := server.AddContainer("project-build", "devstep/project:latest")
+	build.Config.Labels = map[string]string{"io.devstep.project": "/project", "io.devstep.role": "build"}
+	build.Running = false
+
+	other := server.AddContainer("other-hack", "devstep/project:latest")
+	other.Config.Labels = map[string]string{"io.devstep.project": "/other", "io.devstep.role": "hack"}
+	server.AddContainer("unlabeled", "devstep/project:latest")
+
+	containers, err := client.FindContainers(map[string]string{"io.devstep.project": "/project"})
+	ok(t, err)
+	equals(t, 2, len(containers))
+
+	byName := map[string]*devstep.DockerContainer{}
+	for _, container := range containers {
+		byName[container.Name] = container
+	}
+
+	equals(t, hack.ID, byName["project-hack"].ID)
+	assert(t, byName["project-hack"].Running, "Expected the hack container to be running")
+	equals(t, 1, byName["project-hack"].ExecSessions)
+	equals(t, "hack", byName["project-hack"].Labels["io.devstep.role"])
+	equals(t, []devstep.PortMapping{{HostIP: "0.0.0.0", HostPort: "8080", ContainerPort: "80", Protocol: "tcp"}}, byName["project-hack"].Ports)
+
+	assert(t, !byName["project-build"].Running, "Expected the build container to be stopped")
+	equals(t, 0, byName["project-build"].ExecSessions)
+
+	containers, err = client.FindContainers(map[string]string{"io.devstep.role": "hack"})
+	ok(t, err)
+	equals(t, 2, len(containers))
+}
+
+func Test_DockerClientListContainers(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	labels := map[string]string{"io.devstep.project": "/project", "io.devstep.role": "hack"}
+	running := server.AddContainer("running", "some/image:tag")
+	running.Config.Labels = labels
+	stopped := server.AddContainer("stopped", "some/image:tag")
+	stopped.Config.Labels = labels
+	stopped.Running = false
+	server.AddContainer("unlabeled", "some/image:tag")
+
+	containers, err := client.ListContainers(map[string]string{"io.devstep.project": "/project", "io.devstep.role": "hack"})
+	ok(t, err)
+	equals(t, []string{running.ID}, containers)
+
+	_, err = client.ListContainers(map[string]string{})
+	assert(t, err != nil, "Listed containers without labels")
+}
+
+func Test_DockerClientContainerLifecycle(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	status, err := client.ContainerStatus("a-container")
+	ok(t, err)
+	assert(t, status == nil, "Status returned for unknown container")
+
+	container := server.AddContainer("a-container", "some/image")
+
+	status, err = client.ContainerStatus("a-container")
+	ok(t, err)
+	equals(t, &devstep.DockerContainerStatus{ContainerID: container.ID, Image: "some/image", Running: true}, status)
+
+	ok(t, client.StopContainer(container.ID))
+	assert(t, !server.Container(container.ID).Running, "Container was not stopped")
+	ok(t, client.StopContainer(container.ID))
+
+	server.OnStart = func(c *dockertest.Container) {
+		c.Running = true
+	}
+	ok(t, client.StartContainer(container.ID))
+	assert(t, server.Container(container.ID).Running, "Container was not started")
+}
+
+// Runs the function with stdout and stderr redirected, returning what was
+// written to them
+func captureOutput(fn func()) (string, string) {
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+	}()
+
+	outReader, outWriter, _ := os.Pipe()
+	errReader, errWriter, _ := os.Pipe()
+	os.Stdout, os.Stderr = outWriter, errWriter
+
+	outChan, errChan := make(chan string), make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(outReader)
+		outChan <- string(data)
+	}()
+	go func() {
+		data, _ := ioutil.ReadAll(errReader)
+		errChan <- string(data)
+	}()
+
+	fn()
+	outWriter.Close()
+	errWriter.Close()
+	return <-outChan, <-errChan
+}
+
+// The client reads DOCKER_HOST when created, so it gets restored right away
+func newTestClient() (*dockertest.Server, devstep.DockerClient) {
+	server := dockertest.NewServer()
+	previous, wasSet := os.LookupEnv("DOCKER_HOST")
+	os.Setenv("DOCKER_HOST", server.URL())
+	defer func() {
+		if wasSet {
+			os.Setenv("DOCKER_HOST", previous)
+		} else {
+			os.Unsetenv("DOCKER_HOST")
+		}
+	}()
+	return server, devstep.NewClient()
+}
+
+func Test_DockerClientImageContents(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	img := server.AddImage("devstep/project:latest")
+	img.Config.Env = []string{"PATH=/usr/bin", "EMPTY"}
+	img.Config.Labels = map[string]string{"io.devstep.version": "1.0.0"}
+	img.Files = map[string]string{"/usr/bin/ruby": "ruby", "/.dockerenv": ""}
+
+	contents, err := client.ImageContents("devstep/project:latest")
+	ok(t, err)
+
+	equals(t, 4, len(contents.Files))
+	ruby := contents.Files["/usr/bin/ruby"]
+	equals(t, int64(4), ruby.Size)
+	equals(t, "b9138194ffe9e7c8bb6d79d1ed56259553d18d9cb60b66e3ba5aa2e5b078055a", ruby.Checksum)
+	assert(t, contents.Files["/usr/bin"].Mode.IsDir(), "Dirs were not included")
+	equals(t, map[string]string{"env.PATH": "/usr/bin", "env.EMPTY": "", "label.io.devstep.version": "1.0.0"}, contents.Metadata)
+
+	// The container used for exporting the files gets removed
+	equals(t, 0, len(server.Containers()))
+
+	_, err = client.ImageContents("devstep/project:unknown")
+	assert(t, err != nil, "Error for unknown image was swallowed")
+}
+
+func Test_DockerClientContainerContents(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	img := server.AddImage("devstep/project:latest")
+	img.Files = map[string]string{"/etc/motd": "hello"}
+	container := server.AddContainer("project-hack", "devstep/project:latest")
+	container.Files = map[string]string{"/etc/motd": "hello world"}
+	container.Config.WorkingDir = "/workspace"
+
+	contents, err := client.ContainerContents("project-hack")
+	ok(t, err)
+	equals(t, int64(11), contents.Files["/etc/motd"].Size)
+	equals(t, "/workspace", contents.Metadata["working_dir"])
+}
+
+func Test_DockerClientSaveAndLoadImage(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	img := server.AddImage("devstep/project:latest", "devstep/project:20160101000000")
+	img.Config.Labels = map[string]string{"io.devstep.config-hash": "abc"}
+
+	var archive bytes.Buffer
+	ok(t, client.SaveImage("devstep/project:latest", &archive))
+
+	otherServer, otherClient := newTestClient()
+	defer otherServer.Close()
+
+	ok(t, otherClient.LoadImage(&archive))
+	loaded := otherServer.Image("devstep/project:latest")
+	assert(t, loaded != nil, "Image was not loaded")
+	equals(t, img.ID, loaded.ID)
+	equals(t, []string{"devstep/project:latest"}, loaded.RepoTags)
+	equals(t, "abc", loaded.Config.Labels["io.devstep.config-hash"])
+
+	err := otherClient.LoadImage(bytes.NewBufferString("not an archive"))
+	assert(t, err != nil, "Invalid archive was loaded")
+}
+
+func Test_DockerClientPushAndPullImage(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+	defer useDockerConfig(`{"auths":{"https://localhost:5000":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("user:secret")) + `"}}}`)()
+	server.Registry().Username = "user"
+	server.Registry().Password = "secret"
+
+	img := server.AddImage("localhost:5000/devstep/project:latest")
+	ok(t, client.PushImage("localhost:5000/devstep/project", "latest"))
+	equals(t, img.ID, server.RegistryImage("localhost:5000/devstep/project:latest").ID)
+
+	remote := server.AddRegistryImage("localhost:5000/devstep/other:latest")
+	ok(t, client.PullImage("localhost:5000/devstep/other", "latest"))
+	equals(t, remote.ID, server.Image("localhost:5000/devstep/other:latest").ID)
+	equals(t, []string{"user", "user"}, server.RegistryAuths())
+
+	// Credentials are only sent to the registry they belong to
+	err := client.PullImage("devstep/other", "latest")
+	assert(t, err != nil, "Image was pulled from the wrong registry")
+	equals(t, "", server.RegistryAuths()[2])
+
+	err = client.PullImage("localhost:5000/devstep/unknown", "latest")
+	assert(t, err != nil, "Unknown image was pulled")
+}
+
+func useDockerConfig(config string) func() {
+	previous := os.Getenv("DOCKER_CONFIG")
+	dir, _ := ioutil.TempDir("", "devstep-docker-config-")
+	writeFile(dir+"/config.json", config)
+	os.Setenv("DOCKER_CONFIG", dir)
+	return func() {
+		os.Setenv("DOCKER_CONFIG", previous)
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,160 @@
+package devstep
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// The files and settings of an image or container
+type DockerContents struct {
+	Files    map[string]*DockerFile // keyed by absolute path
+	Metadata map[string]string      // settings like `env.PATH` or `cmd`, see configMetadata
+}
+
+// A file, dir or link found on an image or container
+type DockerFile struct {
+	Path     string
+	Mode     os.FileMode
+	Size     int64
+	Checksum string // sha256 of the contents of regular files, the target of links
+}
+
+// Reads the files of an image by exporting a container created (but never
+// started) from it
+func (c *dockerClient) ImageContents(name string) (*DockerContents, error) {
+	log.Info("Reading contents of '%s'", name)
+
+	image, err := c.client.InspectImage(name)
+	if err != nil {
+		return nil, errors.New("Error inspecting image:\n  " + err.Error())
+	}
+
+	container, err := c.client.CreateContainer(docker.CreateContainerOptions{
+		Config: &docker.Config{Image: name, Cmd: []string{"true"}},
+	})
+	if err != nil {
+		return nil, errors.New("Error creating container:\n  " + err.Error())
+	}
+	defer c.RemoveContainer(container.ID)
+
+	files, err := c.exportFiles(container.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerContents{Files: files, Metadata: configMetadata(image.Config)}, nil
+}
+
+// Reads the files of a container, volumes are not included
+func (c *dockerClient) ContainerContents(containerID string) (*DockerContents, error) {
+	log.Info("Reading contents of container '%s'", containerID)
+
+	container, err := c.client.InspectContainer(containerID)
+	if err != nil {
+		return nil, errors.New("Error inspecting container:\n  " + err.Error())
+	}
+
+	files, err := c.exportFiles(container.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerContents{Files: files, Metadata: configMetadata(container.Config)}, nil
+}
+
+func (c *dockerClient) exportFiles(containerID string) (map[string]*DockerFile, error) {
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		writer.CloseWithError(c.client.ExportContainer(docker.ExportContainerOptions{
+			ID:           containerID,
+			OutputStream: writer,
+		}))
+	}()
+
+	files := make(map[string]*DockerFile)
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.New("Error exporting container:\n  " + err.Error())
+		}
+
+		file := &DockerFile{
+			Path: path.Clean("/" + header.Name),
+			Mode: header.FileInfo().Mode(),
+			Size: header.Size,
+		}
+		switch header.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			file.Checksum = header.Linkname
+		case tar.TypeReg, tar.TypeRegA:
+			hash := sha256.New()
+			if _, err = io.Copy(hash, archive); err != nil {
+				return nil, errors.New("Error exporting container:\n  " + err.Error())
+			}
+			file.Checksum = hex.EncodeToString(hash.Sum(nil))
+		}
+		files[file.Path] = file
+	}
+	return files, nil
+}
+
+// Flattens the settings of an image or container that affect the environment
+// into keys like `env.PATH`, `cmd` or `label.io.devstep.version`
+func configMetadata(config *docker.Config) map[string]string {
+	metadata := make(map[string]string)
+	if config == nil {
+		return metadata
+	}
+
+	for _, env := range config.Env {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 {
+			metadata["env."+parts[0]] = parts[1]
+		} else {
+			metadata["env."+parts[0]] = ""
+		}
+	}
+	for k, v := range config.Labels {
+		metadata["label."+k] = v
+	}
+	if len(config.Cmd) > 0 {
+		metadata["cmd"] = strings.Join(config.Cmd, " ")
+	}
+	if len(config.Entrypoint) > 0 {
+		metadata["entrypoint"] = strings.Join(config.Entrypoint, " ")
+	}
+	if config.WorkingDir != "" {
+		metadata["working_dir"] = config.WorkingDir
+	}
+	if config.User != "" {
+		metadata["user"] = config.User
+	}
+	if len(config.ExposedPorts) > 0 {
+		ports := []string{}
+		for port := range config.ExposedPorts {
+			ports = append(ports, string(port))
+		}
+		sort.Strings(ports)
+		metadata["exposed_ports"] = strings.Join(ports, ", ")
+	}
+	if len(config.Volumes) > 0 {
+		volumes := []string{}
+		for volume := range config.Volumes {
+			volumes = append(volumes, volume)
+		}
+		sort.Strings(volumes)
+		metadata["volumes"] = strings.Join(volumes, ", ")
+	}
+	return metadata
+}
@@ -0,0 +1,120 @@
+package devstep
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// The registry used for images whose names don't start with a registry host
+const defaultRegistry = "index.docker.io"
+
+// Pushes a tag of an image to its registry, using the credentials from the
+// Docker config file
+func (c *dockerClient) PushImage(name, tag string) error {
+	log.Info("Pushing '%s:%s'", name, tag)
+	auth, err := registryAuth(name)
+	if err != nil {
+		return err
+	}
+	err = c.client.PushImage(docker.PushImageOptions{
+		Name:         name,
+		Tag:          tag,
+		OutputStream: os.Stdout,
+	}, auth)
+	if err != nil {
+		return errors.New("Error pushing image:\n  " + err.Error())
+	}
+	return nil
+}
+
+// Pulls a tag of an image from its registry, using the credentials from the
+// Docker config file
+func (c *dockerClient) PullImage(name, tag string) error {
+	log.Info("Pulling '%s:%s'", name, tag)
+	auth, err := registryAuth(name)
+	if err != nil {
+		return err
+	}
+	err = c.client.PullImage(docker.PullImageOptions{
+		Repository:   name,
+		Tag:          tag,
+		OutputStream: os.Stdout,
+	}, auth)
+	if err != nil {
+		return errors.New("Error pulling image:\n  " + err.Error())
+	}
+	return nil
+}
+
+// Looks up the credentials for the registry of an image on the Docker config
+// file (`$DOCKER_CONFIG/config.json`, `~/.docker/config.json` or the legacy
+// `~/.dockercfg`), images are pulled and pushed anonymously when there are
+// none
+func registryAuth(name string) (docker.AuthConfiguration, error) {
+	auth := docker.AuthConfiguration{}
+
+	configPath := dockerConfigPath()
+	if configPath == "" {
+		return auth, nil
+	}
+	file, err := os.Open(configPath)
+	if err != nil {
+		return auth, errors.New("Error reading Docker config:\n  " + err.Error())
+	}
+	defer file.Close()
+
+	auths, err := docker.NewAuthConfigurations(file)
+	if err != nil {
+		return auth, errors.New("Error reading credentials from '" + configPath + "':\n  " + err.Error())
+	}
+
+	registry := imageRegistry(name)
+	for address, config := range auths.Configs {
+		if normalizeRegistry(address) == registry {
+			log.Debug("Using credentials for '%s' from '%s'", address, configPath)
+			return config, nil
+		}
+	}
+	return auth, nil
+}
+
+func dockerConfigPath() string {
+	candidates := []string{}
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "config.json"))
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		candidates = append(candidates, filepath.Join(home, ".docker", "config.json"), filepath.Join(home, ".dockercfg"))
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// The registry host of an image name, like `localhost:5000` for
+// `localhost:5000/devstep/project`
+func imageRegistry(name string) string {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return normalizeRegistry(parts[0])
+	}
+	return defaultRegistry
+}
+
+// Registries are listed on the Docker config file as hosts or URLs, like
+// `https://index.docker.io/v1/`
+func normalizeRegistry(address string) string {
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	address = strings.SplitN(address, "/", 2)[0]
+	if address == "docker.io" || address == "registry-1.docker.io" {
+		return defaultRegistry
+	}
+	return address
+}
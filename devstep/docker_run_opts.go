@@ -3,7 +3,6 @@ package devstep
 import (
 	"github.com/fsouza/go-dockerclient"
 	"os"
-	"strings"
 )
 
 type DockerRunOpts struct {
@@ -20,6 +19,8 @@ type DockerRunOpts struct {
 	Image      string
 	Cmd        []string
 	Publish    []string
+	Labels     map[string]string
+	OnStart    func(*DockerRunResult) // called once attached containers are running, with the ports bound
 }
 
 func (this DockerRunOpts) Merge(others ...*DockerRunOpts) *DockerRunOpts {
@@ -37,6 +38,9 @@ func (this DockerRunOpts) Merge(others ...*DockerRunOpts) *DockerRunOpts {
 		if len(other.Cmd) > 0 {
 			this.Cmd = other.Cmd
 		}
+		if other.OnStart != nil {
+			this.OnStart = other.OnStart
+		}
 
 		this.AutoRemove = other.AutoRemove
 		this.Pty = other.Pty
@@ -62,6 +66,16 @@ func (this DockerRunOpts) Merge(others ...*DockerRunOpts) *DockerRunOpts {
 		for k, v := range other.Env {
 			this.Env[k] = v
 		}
+		if len(other.Labels) > 0 {
+			labels := make(map[string]string)
+			for k, v := range this.Labels {
+				labels[k] = v
+			}
+			for k, v := range other.Labels {
+				labels[k] = v
+			}
+			this.Labels = labels
+		}
 	}
 	return &this
 }
@@ -72,10 +86,12 @@ func (opts *DockerRunOpts) toCreateOpts() docker.CreateContainerOptions {
 		env = append(env, k+"="+v)
 	}
 
+	// Containers that are not detached have their output streamed back
+	attachOutput := opts.Pty || !opts.Detach
+
 	exposedPorts := make(map[docker.Port]struct{})
-	for _, port := range opts.Publish {
-		containerPort := strings.Split(port, ":")[1]
-		exposedPorts[docker.Port(containerPort)] = struct{}{}
+	for _, mapping := range opts.portMappings() {
+		exposedPorts[docker.Port(mapping.Port())] = struct{}{}
 	}
 
 	return docker.CreateContainerOptions{
@@ -89,23 +105,23 @@ func (opts *DockerRunOpts) toCreateOpts() docker.CreateContainerOptions {
 			OpenStdin:    opts.Pty,
 			StdinOnce:    opts.Pty,
 			AttachStdin:  opts.Pty,
-			AttachStdout: opts.Pty,
-			AttachStderr: opts.Pty,
+			AttachStdout: attachOutput,
+			AttachStderr: attachOutput,
 			Tty:          opts.Pty,
 			WorkingDir:   opts.Workdir,
+			Labels:       opts.Labels,
 		},
 	}
 }
 
 func (opts *DockerRunOpts) toHostConfig() *docker.HostConfig {
 	portBindings := make(map[docker.Port][]docker.PortBinding)
-	for _, port := range opts.Publish {
-		hostAndContainerPorts := strings.Split(port, ":")
-		hostPort := hostAndContainerPorts[0]
-		containerPort := docker.Port(hostAndContainerPorts[1])
-
-		bindings := portBindings[containerPort]
-		portBindings[containerPort] = append(bindings, docker.PortBinding{HostPort: hostPort})
+	for _, mapping := range opts.portMappings() {
+		port := docker.Port(mapping.Port())
+		portBindings[port] = append(portBindings[port], docker.PortBinding{
+			HostIP:   mapping.HostIP,
+			HostPort: mapping.HostPort,
+		})
 	}
 
 	privileged := false
@@ -139,3 +155,17 @@ func (opts *DockerRunOpts) toAttachOpts(containerID string) docker.AttachToConta
 
 	return attachOpts
 }
+
+// Ports are validated when parsing the config and command line arguments, so
+// invalid ones are ignored here
+func (opts *DockerRunOpts) portMappings() []PortMapping {
+	mappings := []PortMapping{}
+	for _, spec := range opts.Publish {
+		if parsed, err := ParsePorts(spec); err == nil {
+			mappings = append(mappings, parsed...)
+		} else {
+			log.Warning("Ignoring %s", err)
+		}
+	}
+	return mappings
+}
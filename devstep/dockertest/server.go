@@ -0,0 +1,1213 @@
+// Package dockertest provides an in-process fake of the Docker Remote API so
+// that devstep's DockerClient can be exercised without a real daemon.
+//
+// The server keeps its state (images, containers and exec instances) in
+// memory and exposes it so that tests can prepare fixtures and make
+// assertions. The behavior of containers and exec instances can be scripted
+// with the OnStart and OnExec hooks.
+package dockertest
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of filesystem changes, as returned by the changes endpoint
+const (
+	ChangeModify = iota
+	ChangeAdd
+	ChangeDelete
+)
+
+// A fake Docker daemon
+type Server struct {
+	// Called right after a container gets started, it can be used to script
+	// the container behavior by setting its output, exit code and changes.
+	// Containers are considered to be finished after the hook returns unless
+	// Running is set to true, without a hook they keep running until stopped.
+	OnStart func(*Container)
+
+	// Called when an exec instance gets started, the exec is considered to be
+	// finished after the hook returns unless Running is set to true.
+	OnExec func(*Exec)
+
+	mu         sync.Mutex
+	httpServer *httptest.Server
+	images     []*Image
+	containers []*Container
+	execs      map[string]*Exec
+	requests   []string
+	lastPort   int
+	registry   *Registry
+}
+
+// A stand-in for a Docker registry that images get pushed to and pulled from
+type Registry struct {
+	// Credentials required for pushing and pulling, anonymous access is
+	// allowed when blank
+	Username string
+	Password string
+
+	images map[string]*Image
+	auths  []string // usernames used for each push and pull, blank for anonymous ones
+}
+
+type Config struct {
+	Hostname     string              `json:"Hostname,omitempty"`
+	User         string              `json:"User,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Image        string              `json:"Image,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Tty          bool                `json:"Tty,omitempty"`
+	OpenStdin    bool                `json:"OpenStdin,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+}
+
+type PortBinding struct {
+	HostIP   string `json:"HostIp,omitempty"`
+	HostPort string `json:"HostPort,omitempty"`
+}
+
+type HostConfig struct {
+	Binds        []string                 `json:"Binds,omitempty"`
+	Links        []string                 `json:"Links,omitempty"`
+	Privileged   bool                     `json:"Privileged,omitempty"`
+	PortBindings map[string][]PortBinding `json:"PortBindings,omitempty"`
+}
+
+type Change struct {
+	Path string `json:"Path"`
+	Kind int    `json:"Kind"`
+}
+
+type Container struct {
+	ID         string
+	Name       string
+	Config     *Config
+	HostConfig *HostConfig
+	Created    time.Time
+	Running    bool
+	Started    bool
+	ExitCode   int
+	Stdout     string
+	Stderr     string
+	Changes    []Change
+	ExecIDs    []string
+	Ports      map[string][]PortBinding // ports bound on the host while running
+	Signals    []int                    // signals sent to the container
+	Files      map[string]string        // contents of the files on the container, copied from the image
+
+	done chan struct{}
+}
+
+type Exec struct {
+	ID          string
+	ContainerID string
+	Cmd         []string
+	User        string
+	Tty         bool
+	Running     bool
+	ExitCode    int
+	Stdout      string
+	Stderr      string
+}
+
+type Image struct {
+	ID        string
+	RepoTags  []string
+	Created   time.Time
+	Size      int64
+	Comment   string
+	Author    string
+	Container string
+	Paused    bool // whether the container was paused while being commited
+	Config    *Config
+	Files     map[string]string // contents of the files on the image, keyed by path
+}
+
+// Starts a new fake daemon listening on a random local port
+func NewServer() *Server {
+	s := &Server{execs: map[string]*Exec{}, registry: &Registry{images: map[string]*Image{}}}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// The endpoint that should be used to reach the server, suitable for
+// assigning to DOCKER_HOST
+func (s *Server) URL() string {
+	return "tcp://" + s.httpServer.Listener.Addr().String()
+}
+
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// Registers an image with the provided repository tags
+func (s *Server) AddImage(repoTags ...string) *Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img := &Image{ID: newID(), Created: time.Now(), Config: &Config{}}
+	s.images = append(s.images, img)
+	for _, repoTag := range repoTags {
+		s.tagImage(img, repoTag)
+	}
+	return img
+}
+
+// Registers a container that is already running and has no exec instances
+func (s *Server) AddContainer(name, image string) *Container {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &Container{
+		ID:         newID(),
+		Name:       name,
+		Config:     &Config{Image: image},
+		HostConfig: &HostConfig{},
+		Created:    time.Now(),
+		Running:    true,
+		Started:    true,
+		done:       make(chan struct{}),
+	}
+	s.containers = append(s.containers, c)
+	return c
+}
+
+// Registers an exec instance for the provided container
+func (s *Server) AddExec(containerID string, running bool) *Exec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.findContainer(containerID)
+	if c == nil {
+		panic("Unknown container '" + containerID + "'")
+	}
+	exec := &Exec{ID: newID(), ContainerID: c.ID, Running: running}
+	s.execs[exec.ID] = exec
+	c.ExecIDs = append(c.ExecIDs, exec.ID)
+	return exec
+}
+
+// Looks up a container by ID, ID prefix or name
+func (s *Server) Container(idOrName string) *Container {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.findContainer(idOrName)
+}
+
+func (s *Server) Containers() []*Container {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Container{}, s.containers...)
+}
+
+// Looks up an image by ID or repository tag
+func (s *Server) Image(name string) *Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.findImage(name)
+}
+
+func (s *Server) Images() []*Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Image{}, s.images...)
+}
+
+func (s *Server) Exec(id string) *Exec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.execs[id]
+}
+
+// The list of requests received so far, formatted as "METHOD /path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+var versionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := versionPrefix.ReplaceAllString(r.URL.Path, "/")
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+path)
+	s.mu.Unlock()
+
+	switch {
+	case path == "/_ping":
+		w.Write([]byte("OK"))
+	case path == "/version":
+		writeJSON(w, http.StatusOK, map[string]string{"ApiVersion": "1.22", "Version": "1.10.0"})
+	case r.Method == "POST" && path == "/containers/create":
+		s.createContainer(w, r)
+	case r.Method == "GET" && path == "/containers/json":
+		s.listContainers(w, r)
+	case strings.HasPrefix(path, "/containers/"):
+		s.serveContainer(w, r, strings.TrimPrefix(path, "/containers/"))
+	case strings.HasPrefix(path, "/exec/"):
+		s.serveExec(w, r, strings.TrimPrefix(path, "/exec/"))
+	case r.Method == "POST" && path == "/commit":
+		s.commitContainer(w, r)
+	case r.Method == "GET" && path == "/images/json":
+		s.listImages(w, r)
+	case r.Method == "POST" && path == "/images/load":
+		s.loadImage(w, r)
+	case r.Method == "POST" && path == "/images/create":
+		s.pullImage(w, r)
+	case strings.HasPrefix(path, "/images/"):
+		s.serveImage(w, r, strings.TrimPrefix(path, "/images/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveContainer(w http.ResponseWriter, r *http.Request, path string) {
+	idOrName, action := path, ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		idOrName, action = path[:i], path[i+1:]
+	}
+
+	s.mu.Lock()
+	c := s.findContainer(idOrName)
+	s.mu.Unlock()
+	if c == nil {
+		http.Error(w, "No such container: "+idOrName, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == "DELETE" && action == "":
+		s.removeContainer(w, c)
+	case r.Method == "GET" && action == "json":
+		s.inspectContainer(w, c)
+	case r.Method == "GET" && action == "changes":
+		s.mu.Lock()
+		changes := append([]Change{}, c.Changes...)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, changes)
+	case r.Method == "POST" && action == "start":
+		s.startContainer(w, r, c)
+	case r.Method == "POST" && action == "stop":
+		s.stopContainer(w, c)
+	case r.Method == "POST" && action == "kill":
+		s.killContainer(w, r, c)
+	case r.Method == "POST" && action == "wait":
+		<-s.doneChan(c)
+		s.mu.Lock()
+		exitCode := c.ExitCode
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]int{"StatusCode": exitCode})
+	case r.Method == "POST" && action == "attach":
+		s.attachContainer(w, c)
+	case r.Method == "POST" && action == "exec":
+		s.createExec(w, r, c)
+	case r.Method == "GET" && action == "export":
+		s.exportContainer(w, c)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) createContainer(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Config
+		HostConfig *HostConfig `json:"HostConfig,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img := s.findImage(body.Image)
+	if img == nil {
+		http.Error(w, "No such image: "+body.Image, http.StatusNotFound)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name != "" && s.findContainer(name) != nil {
+		http.Error(w, "Conflict. The name '"+name+"' is already in use", http.StatusConflict)
+		return
+	}
+	if body.HostConfig == nil {
+		body.HostConfig = &HostConfig{}
+	}
+	// Containers inherit the labels of their images
+	if img.Config != nil && len(img.Config.Labels) > 0 {
+		labels := make(map[string]string)
+		for k, v := range img.Config.Labels {
+			labels[k] = v
+		}
+		for k, v := range body.Labels {
+			labels[k] = v
+		}
+		body.Labels = labels
+	}
+
+	c := &Container{
+		ID:         newID(),
+		Name:       name,
+		Config:     &body.Config,
+		HostConfig: body.HostConfig,
+		Created:    time.Now(),
+		Files:      copyFiles(img.Files),
+		done:       make(chan struct{}),
+	}
+	s.containers = append(s.containers, c)
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": c.ID})
+}
+
+// Writes the files of the container as a tar archive, along with the dirs
+// they are in
+func (s *Server) exportContainer(w http.ResponseWriter, c *Container) {
+	s.mu.Lock()
+	files := copyFiles(c.Files)
+	s.mu.Unlock()
+
+	paths := []string{}
+	dirs := map[string]bool{}
+	for name := range files {
+		paths = append(paths, name)
+		for dir := path.Dir(name); dir != "/" && dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	for dir := range dirs {
+		paths = append(paths, dir+"/")
+	}
+	sort.Strings(paths)
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	archive := tar.NewWriter(w)
+	for _, name := range paths {
+		if strings.HasSuffix(name, "/") {
+			archive.WriteHeader(&tar.Header{Name: strings.TrimPrefix(name, "/"), Mode: 0755, Typeflag: tar.TypeDir})
+			continue
+		}
+		archive.WriteHeader(&tar.Header{Name: strings.TrimPrefix(name, "/"), Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg})
+		archive.Write([]byte(files[name]))
+	}
+	archive.Close()
+}
+
+func (s *Server) startContainer(w http.ResponseWriter, r *http.Request, c *Container) {
+	var hostConfig HostConfig
+	body, _ := ioutil.ReadAll(r.Body)
+	if len(body) > 0 && string(body) != "null" {
+		if err := json.Unmarshal(body, &hostConfig); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.Running {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if len(body) > 0 && string(body) != "null" {
+		c.HostConfig = &hostConfig
+	}
+	if c.Started {
+		c.done = make(chan struct{})
+	}
+	c.Started = true
+	c.Ports = s.bindPorts(c.HostConfig)
+	c.Running = s.OnStart == nil
+	if s.OnStart != nil {
+		s.OnStart(c)
+	}
+	if !c.Running {
+		c.Ports = nil
+		close(c.done)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) stopContainer(w http.ResponseWriter, c *Container) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !c.Running {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	c.Running = false
+	c.Ports = nil
+	close(c.done)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Containers exit right away when they receive a signal, with the exit code
+// set the same way shells do
+func (s *Server) killContainer(w http.ResponseWriter, r *http.Request, c *Container) {
+	signal := 9
+	if sig := r.URL.Query().Get("signal"); sig != "" {
+		var err error
+		if signal, err = strconv.Atoi(sig); err != nil {
+			http.Error(w, "Invalid signal: "+sig, http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !c.Running {
+		http.Error(w, "Container "+c.ID+" is not running", http.StatusConflict)
+		return
+	}
+	c.Signals = append(c.Signals, signal)
+	c.Running = false
+	c.ExitCode = 128 + signal
+	c.Ports = nil
+	close(c.done)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Assigns host ports the same way the daemon does, picking sequential ports
+// starting at 32768 when no host port is provided
+func (s *Server) bindPorts(hostConfig *HostConfig) map[string][]PortBinding {
+	if hostConfig == nil || len(hostConfig.PortBindings) == 0 {
+		return nil
+	}
+
+	ports := map[string][]PortBinding{}
+	for port, bindings := range hostConfig.PortBindings {
+		for _, binding := range bindings {
+			if binding.HostIP == "" {
+				binding.HostIP = "0.0.0.0"
+			}
+			if binding.HostPort == "" {
+				if s.lastPort == 0 {
+					s.lastPort = 32767
+				}
+				s.lastPort++
+				binding.HostPort = strconv.Itoa(s.lastPort)
+			}
+			ports[port] = append(ports[port], binding)
+		}
+	}
+	return ports
+}
+
+func (s *Server) inspectContainer(w http.ResponseWriter, c *Container) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"Id":         c.ID,
+		"Name":       "/" + c.Name,
+		"Created":    c.Created,
+		"Image":      c.Config.Image,
+		"Config":     c.Config,
+		"HostConfig": c.HostConfig,
+		"ExecIDs":    c.ExecIDs,
+		"NetworkSettings": map[string]interface{}{
+			"Ports": c.Ports,
+		},
+		"State": map[string]interface{}{
+			"Running":  c.Running,
+			"ExitCode": c.ExitCode,
+		},
+	})
+}
+
+func (s *Server) listContainers(w http.ResponseWriter, r *http.Request) {
+	filters := map[string][]string{}
+	if f := r.URL.Query().Get("filters"); f != "" {
+		if err := json.Unmarshal([]byte(f), &filters); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	all := r.URL.Query().Get("all")
+	showAll := all == "1" || all == "true"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []map[string]interface{}{}
+	for _, c := range s.containers {
+		status := "created"
+		if c.Running {
+			status = "running"
+		} else if c.Started {
+			status = "exited"
+		}
+		if !showAll && !c.Running && len(filters["status"]) == 0 {
+			continue
+		}
+		if statuses := filters["status"]; len(statuses) > 0 && !contains(statuses, status) {
+			continue
+		}
+		if !hasLabels(c.Config.Labels, filters["label"]) {
+			continue
+		}
+		ports := []map[string]interface{}{}
+		for port, bindings := range c.Ports {
+			parts := strings.SplitN(port, "/", 2)
+			privatePort, _ := strconv.Atoi(parts[0])
+			protocol := "tcp"
+			if len(parts) == 2 {
+				protocol = parts[1]
+			}
+			for _, binding := range bindings {
+				publicPort, _ := strconv.Atoi(binding.HostPort)
+				ports = append(ports, map[string]interface{}{
+					"IP":          binding.HostIP,
+					"PrivatePort": privatePort,
+					"PublicPort":  publicPort,
+					"Type":        protocol,
+				})
+			}
+		}
+		result = append(result, map[string]interface{}{
+			"Id":      c.ID,
+			"Image":   c.Config.Image,
+			"Names":   []string{"/" + c.Name},
+			"Created": c.Created.Unix(),
+			"State":   status,
+			"Status":  humanStatus(c, status),
+			"Labels":  c.Config.Labels,
+			"Ports":   ports,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) removeContainer(w http.ResponseWriter, c *Container) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, other := range s.containers {
+		if other == c {
+			s.containers = append(s.containers[:i], s.containers[i+1:]...)
+			break
+		}
+	}
+	if c.Running {
+		c.Running = false
+		close(c.done)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Waits for the container to finish and streams back its scripted output,
+// multiplexing stdout and stderr unless the container has a TTY.
+func (s *Server) attachContainer(w http.ResponseWriter, c *Container) {
+	conn, buf, err := hijack(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	go io.Copy(ioutil.Discard, buf)
+
+	<-s.doneChan(c)
+
+	s.mu.Lock()
+	tty, stdout, stderr := c.Config.Tty, c.Stdout, c.Stderr
+	s.mu.Unlock()
+	writeStreams(conn, tty, stdout, stderr)
+}
+
+func (s *Server) createExec(w http.ResponseWriter, r *http.Request, c *Container) {
+	var opts struct {
+		Cmd  []string
+		User string
+		Tty  bool
+	}
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !c.Running {
+		http.Error(w, "Container "+c.ID+" is not running", http.StatusConflict)
+		return
+	}
+	exec := &Exec{ID: newID(), ContainerID: c.ID, Cmd: opts.Cmd, User: opts.User, Tty: opts.Tty}
+	s.execs[exec.ID] = exec
+	c.ExecIDs = append(c.ExecIDs, exec.ID)
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": exec.ID})
+}
+
+func (s *Server) serveExec(w http.ResponseWriter, r *http.Request, path string) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	exec := s.execs[parts[0]]
+	s.mu.Unlock()
+	if exec == nil {
+		http.Error(w, "No such exec instance: "+parts[0], http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && parts[1] == "json":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"ID":       exec.ID,
+			"Running":  exec.Running,
+			"ExitCode": exec.ExitCode,
+			"ProcessConfig": map[string]interface{}{
+				"user":      exec.User,
+				"tty":       exec.Tty,
+				"arguments": exec.Cmd,
+			},
+		})
+	case r.Method == "POST" && parts[1] == "start":
+		s.startExec(w, r, exec)
+	case r.Method == "POST" && parts[1] == "resize":
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) startExec(w http.ResponseWriter, r *http.Request, exec *Exec) {
+	var opts struct{ Detach bool }
+	json.NewDecoder(r.Body).Decode(&opts)
+
+	s.mu.Lock()
+	exec.Running = false
+	if s.OnExec != nil {
+		s.OnExec(exec)
+	}
+	tty, stdout, stderr := exec.Tty, exec.Stdout, exec.Stderr
+	s.mu.Unlock()
+
+	if opts.Detach {
+		writeJSON(w, http.StatusOK, map[string]string{})
+		return
+	}
+
+	conn, buf, err := hijack(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	go io.Copy(ioutil.Discard, buf)
+
+	writeStreams(conn, tty, stdout, stderr)
+}
+
+func (s *Server) commitContainer(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var run Config
+	json.NewDecoder(r.Body).Decode(&run)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.findContainer(query.Get("container"))
+	if c == nil {
+		http.Error(w, "No such container: "+query.Get("container"), http.StatusNotFound)
+		return
+	}
+
+	// Labels provided when commiting are added to the ones from the container
+	config := *c.Config
+	if len(run.Labels) > 0 {
+		config.Labels = make(map[string]string)
+		for k, v := range c.Config.Labels {
+			config.Labels[k] = v
+		}
+		for k, v := range run.Labels {
+			config.Labels[k] = v
+		}
+	}
+	img := &Image{
+		ID:        newID(),
+		Created:   time.Now(),
+		Comment:   query.Get("comment"),
+		Author:    query.Get("author"),
+		Container: c.ID,
+		Paused:    query.Get("pause") != "0" && query.Get("pause") != "false",
+		Config:    &config,
+		Files:     copyFiles(c.Files),
+	}
+	s.images = append(s.images, img)
+	if repo := query.Get("repo"); repo != "" {
+		tag := query.Get("tag")
+		if tag == "" {
+			tag = "latest"
+		}
+		s.tagImage(img, repo+":"+tag)
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": img.ID})
+}
+
+func (s *Server) listImages(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []map[string]interface{}{}
+	for _, img := range s.images {
+		result = append(result, map[string]interface{}{
+			"Id":          img.ID,
+			"RepoTags":    img.RepoTags,
+			"Created":     img.Created.Unix(),
+			"Size":        img.Size,
+			"VirtualSize": img.Size,
+			"Labels":      img.Config.Labels,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) serveImage(w http.ResponseWriter, r *http.Request, path string) {
+	name, action := path, ""
+	if i := strings.LastIndex(path, "/"); i >= 0 && r.Method != "DELETE" {
+		name, action = path[:i], path[i+1:]
+	}
+	if r.Method == "POST" && action == "push" {
+		s.pushImage(w, r, name)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img := s.findImage(name)
+	if img == nil {
+		http.Error(w, "No such image: "+name, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && action == "json":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"Id":        img.ID,
+			"RepoTags":  img.RepoTags,
+			"Created":   img.Created,
+			"Size":      img.Size,
+			"Comment":   img.Comment,
+			"Author":    img.Author,
+			"Container": img.Container,
+			"Config":    img.Config,
+		})
+	case r.Method == "POST" && action == "tag":
+		query := r.URL.Query()
+		tag := query.Get("tag")
+		if tag == "" {
+			tag = "latest"
+		}
+		s.tagImage(img, query.Get("repo")+":"+tag)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "GET" && action == "get":
+		s.saveImage(w, img, name)
+	case r.Method == "DELETE":
+		s.removeImage(w, img, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// The manifest of archives created by `docker save`
+type manifestEntry struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// The image config stored on archives, files are kept along with it instead
+// of on layers
+type archivedImage struct {
+	ID      string            `json:"id"`
+	Created time.Time         `json:"created"`
+	Config  *Config           `json:"config"`
+	Files   map[string]string `json:"files,omitempty"`
+}
+
+// Writes an archive in the format used by `docker save`, only the tag used
+// for referencing the image is included. Must be called with the lock held.
+func (s *Server) saveImage(w http.ResponseWriter, img *Image, name string) {
+	repoTags := []string{}
+	if contains(img.RepoTags, name) {
+		repoTags = append(repoTags, name)
+	}
+	manifest, _ := json.Marshal([]manifestEntry{{Config: img.ID + ".json", RepoTags: repoTags, Layers: []string{}}})
+	config, _ := json.Marshal(archivedImage{ID: img.ID, Created: img.Created, Config: img.Config, Files: img.Files})
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	archive := tar.NewWriter(w)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{{img.ID + ".json", config}, {"manifest.json", manifest}} {
+		archive.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.data)), Typeflag: tar.TypeReg})
+		archive.Write(entry.data)
+	}
+	archive.Close()
+}
+
+// Registers the images from an archive created by saveImage
+func (s *Server) loadImage(w http.ResponseWriter, r *http.Request) {
+	var manifest []manifestEntry
+	configs := map[string]*archivedImage{}
+
+	archive := tar.NewReader(r.Body)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			http.Error(w, "Invalid archive: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if header.Name == "manifest.json" {
+			err = json.NewDecoder(archive).Decode(&manifest)
+		} else if strings.HasSuffix(header.Name, ".json") {
+			config := &archivedImage{}
+			err = json.NewDecoder(archive).Decode(config)
+			configs[header.Name] = config
+		}
+		if err != nil {
+			http.Error(w, "Invalid archive: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range manifest {
+		config := configs[entry.Config]
+		if config == nil {
+			http.Error(w, "Invalid archive: missing "+entry.Config, http.StatusInternalServerError)
+			return
+		}
+		img := s.findImage(config.ID)
+		if img == nil {
+			img = &Image{ID: config.ID, Created: config.Created, Config: config.Config, Files: config.Files}
+			s.images = append(s.images, img)
+		}
+		for _, repoTag := range entry.RepoTags {
+			s.tagImage(img, repoTag)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// The registry stand-in of the server
+func (s *Server) Registry() *Registry {
+	return s.registry
+}
+
+// Registers an image on the registry, as if it had been pushed by someone else
+func (s *Server) AddRegistryImage(repoTags ...string) *Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img := &Image{ID: newID(), Created: time.Now(), Config: &Config{}, RepoTags: repoTags}
+	for _, repoTag := range repoTags {
+		s.registry.images[repoTag] = img
+	}
+	return img
+}
+
+// Looks up an image pushed to the registry by its repository tag
+func (s *Server) RegistryImage(repoTag string) *Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.registry.images[repoTag]
+}
+
+// The usernames sent along with each push and pull, blank for anonymous ones
+func (s *Server) RegistryAuths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.registry.auths...)
+}
+
+func (s *Server) pushImage(w http.ResponseWriter, r *http.Request, name string) {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		tag = "latest"
+	}
+	repoTag := name + ":" + tag
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img := s.findImage(repoTag)
+	if img == nil {
+		http.Error(w, "No such image: "+repoTag, http.StatusNotFound)
+		return
+	}
+	if err := s.registry.authorize(r); err != "" {
+		writeStreamError(w, err)
+		return
+	}
+	s.registry.images[repoTag] = img
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Pushed " + repoTag})
+}
+
+func (s *Server) pullImage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tag := query.Get("tag")
+	if tag == "" {
+		tag = "latest"
+	}
+	repoTag := query.Get("fromImage") + ":" + tag
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.registry.authorize(r); err != "" {
+		writeStreamError(w, err)
+		return
+	}
+	remote := s.registry.images[repoTag]
+	if remote == nil {
+		writeStreamError(w, "Error: image "+repoTag+" not found")
+		return
+	}
+
+	img := s.findImage(remote.ID)
+	if img == nil {
+		img = &Image{ID: remote.ID, Created: remote.Created, Size: remote.Size, Config: remote.Config, Files: copyFiles(remote.Files)}
+		s.images = append(s.images, img)
+	}
+	s.tagImage(img, repoTag)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Downloaded newer image for " + repoTag})
+}
+
+// Records the credentials sent along with a request, returning an error
+// message when they are required and don't match. Must be called with the
+// lock held.
+func (reg *Registry) authorize(r *http.Request) string {
+	var auth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if header := r.Header.Get("X-Registry-Auth"); header != "" {
+		data, _ := base64.URLEncoding.DecodeString(header)
+		json.Unmarshal(data, &auth)
+	}
+	reg.auths = append(reg.auths, auth.Username)
+
+	if reg.Username != "" && (auth.Username != reg.Username || auth.Password != reg.Password) {
+		return "unauthorized: authentication required"
+	}
+	return ""
+}
+
+// Errors that happen while pushing and pulling are reported on the progress
+// stream, like Docker does
+func writeStreamError(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"error":       message,
+		"errorDetail": map[string]string{"message": message},
+	})
+}
+
+// Untags the image if it was referenced by one of its tags, removing it
+// completely once it has no tags left
+func (s *Server) removeImage(w http.ResponseWriter, img *Image, name string) {
+	deleted := []map[string]string{}
+	for i, repoTag := range img.RepoTags {
+		if repoTag == name {
+			img.RepoTags = append(img.RepoTags[:i], img.RepoTags[i+1:]...)
+			deleted = append(deleted, map[string]string{"Untagged": repoTag})
+			break
+		}
+	}
+	if len(img.RepoTags) == 0 || name == img.ID {
+		for i, other := range s.images {
+			if other == img {
+				s.images = append(s.images[:i], s.images[i+1:]...)
+				break
+			}
+		}
+		deleted = append(deleted, map[string]string{"Deleted": img.ID})
+	}
+	writeJSON(w, http.StatusOK, deleted)
+}
+
+// Returns a channel that gets closed once the container stops running
+func (s *Server) doneChan(c *Container) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return c.done
+}
+
+// Must be called with the lock held
+func (s *Server) tagImage(img *Image, repoTag string) {
+	if !strings.Contains(repoTag[strings.LastIndex(repoTag, "/")+1:], ":") {
+		repoTag += ":latest"
+	}
+	for _, other := range s.images {
+		for i, t := range other.RepoTags {
+			if t == repoTag {
+				other.RepoTags = append(other.RepoTags[:i], other.RepoTags[i+1:]...)
+				break
+			}
+		}
+	}
+	img.RepoTags = append(img.RepoTags, repoTag)
+}
+
+// Must be called with the lock held
+func (s *Server) findContainer(idOrName string) *Container {
+	idOrName = strings.TrimPrefix(idOrName, "/")
+	if idOrName == "" {
+		return nil
+	}
+	for _, c := range s.containers {
+		if c.ID == idOrName || c.Name == idOrName {
+			return c
+		}
+	}
+	for _, c := range s.containers {
+		if strings.HasPrefix(c.ID, idOrName) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Must be called with the lock held
+func (s *Server) findImage(name string) *Image {
+	if name == "" {
+		return nil
+	}
+	repoTag := name
+	if !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		repoTag += ":latest"
+	}
+	for _, img := range s.images {
+		if img.ID == name || contains(img.RepoTags, repoTag) {
+			return img
+		}
+	}
+	return nil
+}
+
+// Checks label filters in the `key` or `key=value` formats
+func hasLabels(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		parts := strings.SplitN(filter, "=", 2)
+		value, found := labels[parts[0]]
+		if !found || (len(parts) == 2 && value != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func humanStatus(c *Container, status string) string {
+	switch status {
+	case "running":
+		return "Up " + time.Since(c.Created).String()
+	case "exited":
+		return "Exited (" + strconv.Itoa(c.ExitCode) + ")"
+	}
+	return "Created"
+}
+
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Connection can't be hijacked")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprint(conn, "HTTP/1.1 200 OK\r\nContent-Type: application/vnd.docker.raw-stream\r\n\r\n")
+	return conn, buf, nil
+}
+
+// Writes the output of a process the same way the daemon does, as a raw
+// stream for TTYs or multiplexed using stdcopy frames otherwise
+func writeStreams(w io.Writer, tty bool, stdout, stderr string) {
+	if tty {
+		io.WriteString(w, stdout+stderr)
+		return
+	}
+	writeFrame(w, 1, stdout)
+	writeFrame(w, 2, stderr)
+}
+
+func writeFrame(w io.Writer, stream byte, data string) {
+	if data == "" {
+		return
+	}
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	w.Write(header)
+	io.WriteString(w, data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func copyFiles(files map[string]string) map[string]string {
+	if files == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(files))
+	for name, contents := range files {
+		copied[name] = contents
+	}
+	return copied
+}
@@ -0,0 +1,11 @@
+package devstep
+
+import "os"
+
+// Replaces the signal notifier so that tests can send signals to devstep
+// without signalling the test process, returns a function that restores it
+func UseSignalNotifier(notify func(chan<- os.Signal) func()) func() {
+	previous := notifySignals
+	notifySignals = notify
+	return func() { notifySignals = previous }
+}
@@ -0,0 +1,114 @@
+package devstep
+
+import (
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Running hack containers without exec instances are only considered to be
+// orphaned after this period so that we don't remove containers whose
+// session is about to start
+var HackSessionGracePeriod = time.Minute
+
+// Containers created by devstep before they were labeled are named after the
+// project dir followed by a timestamp
+var legacyContainerName = regexp.MustCompile(`^([a-zA-Z0-9][a-zA-Z0-9_.-]*)-\d{14}$`)
+
+// Repository of the images that environments used to be started from when
+// containers were not labeled
+const legacySourceRepository = "fgrehm/devstep"
+
+// A container left behind by devstep along with the reason why it can be
+// removed
+type OrphanedContainer struct {
+	*DockerContainer
+	Reason string
+}
+
+// Finds containers of all projects that were left behind, like hack
+// containers whose sessions have ended or build containers that were not
+// removed because devstep got killed. Only containers created before the
+// provided age are returned.
+func FindOrphanedContainers(client DockerClient, olderThan time.Duration) ([]*OrphanedContainer, error) {
+	containers, err := client.FindContainers(map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	orphans := []*OrphanedContainer{}
+	for _, container := range containers {
+		age := now.Sub(container.Created)
+		if age < olderThan {
+			continue
+		}
+		if reason := orphanReason(container, age); reason != "" {
+			orphans = append(orphans, &OrphanedContainer{container, reason})
+		}
+	}
+
+	sort.Sort(orphansByDate(orphans))
+	return orphans, nil
+}
+
+func orphanReason(container *DockerContainer, age time.Duration) string {
+	role, labeled := container.Labels[LabelRole]
+	if !labeled {
+		// We can't tell what unlabeled containers are running, so only the
+		// ones that have stopped are removed
+		if legacyContainer(container) && !container.Running {
+			return "stopped"
+		}
+		return ""
+	}
+
+	switch role {
+	case RoleHack:
+		if !container.Running {
+			return "stopped"
+		}
+		// Attached containers live as long as the `devstep hack` process
+		// that started them, so they are not reaped while running
+		if container.Labels[LabelAttached] == "true" || container.Labels[LabelKeep] == "true" {
+			return ""
+		}
+		if container.ExecSessions == 0 && age >= HackSessionGracePeriod && !hasSessions(container) {
+			return "no sessions"
+		}
+	case RoleBuild, RoleBootstrap, RoleRun:
+		if !container.Running {
+			return "exited"
+		}
+	}
+	return ""
+}
+
+// Legacy containers are only considered when they were created from the
+// project repository (named after the project dir) or from the source image
+func legacyContainer(container *DockerContainer) bool {
+	match := legacyContainerName.FindStringSubmatch(container.Name)
+	if match == nil {
+		return false
+	}
+	repository, _ := splitRepositoryTag(container.Image)
+	return repository == "devstep/"+match[1] || repository == legacySourceRepository
+}
+
+// Sessions registered by `devstep hack` processes that are still alive keep
+// the container around even if no exec instances are running at the moment
+func hasSessions(container *DockerContainer) bool {
+	count, err := registeredSessions(container.Labels[LabelProject], container.ID)
+	if err != nil {
+		log.Info("Error reading sessions of '%s': %s", container.Name, err)
+		return true
+	}
+	return count > 0
+}
+
+// Sorts containers from the oldest to the newest
+type orphansByDate []*OrphanedContainer
+
+func (o orphansByDate) Len() int           { return len(o) }
+func (o orphansByDate) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
+func (o orphansByDate) Less(i, j int) bool { return o[i].Created.Before(o[j].Created) }
@@ -0,0 +1,110 @@
+package devstep_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_FindOrphanedContainers(t *testing.T) {
+	now := time.Now()
+	labeled := func(role string) map[string]string {
+		return map[string]string{devstep.LabelProject: "/project", devstep.LabelRole: role}
+	}
+
+	attached := labeled("hack")
+	attached[devstep.LabelAttached] = "true"
+
+	clientMock := NewMockClient()
+	var filters map[string]string
+	clientMock.FindContainersFunc = func(labels map[string]string) ([]*devstep.DockerContainer, error) {
+		filters = labels
+		return []*devstep.DockerContainer{
+			{Name: "hack-stopped", Labels: labeled("hack"), Created: now.Add(-3 * time.Hour)},
+			{Name: "hack-without-sessions", Labels: labeled("hack"), Running: true, Created: now.Add(-2 * time.Hour)},
+			{Name: "hack-starting", Labels: labeled("hack"), Running: true, Created: now},
+			{Name: "hack-in-use", Labels: labeled("hack"), Running: true, ExecSessions: 1, Created: now.Add(-time.Hour)},
+			{Name: "hack-attached", Labels: attached, Running: true, Created: now.Add(-2 * time.Hour)},
+			{Name: "build-exited", Labels: labeled("build"), Created: now.Add(-4 * time.Hour)},
+			{Name: "build-running", Labels: labeled("build"), Running: true, Created: now.Add(-time.Hour)},
+			{Name: "service", Labels: labeled("service"), Created: now.Add(-time.Hour)},
+			{Name: "project-20150101000000", Image: "devstep/project:latest", Created: now.Add(-5 * time.Hour)},
+			{Name: "project-20150102000000", Image: "devstep/project", Running: true, Created: now.Add(-5 * time.Hour)},
+			{Name: "project-20150103000000", Image: "fgrehm/devstep:v0.4.0", Created: now.Add(-6 * time.Hour)},
+			{Name: "backup-20150101000000", Image: "mysql:5.6", Created: now.Add(-5 * time.Hour)},
+			{Name: "unrelated", Created: now.Add(-5 * time.Hour)},
+		}, nil
+	}
+
+	orphans, err := devstep.FindOrphanedContainers(clientMock, 0)
+	ok(t, err)
+	equals(t, map[string]string{}, filters)
+
+	found := map[string]string{}
+	names := []string{}
+	for _, orphan := range orphans {
+		found[orphan.Name] = orphan.Reason
+		names = append(names, orphan.Name)
+	}
+	equals(t, map[string]string{
+		"project-20150101000000": "stopped",
+		"project-20150103000000": "stopped",
+		"build-exited":           "exited",
+		"hack-stopped":           "stopped",
+		"hack-without-sessions":  "no sessions",
+	}, found)
+	equals(t, "project-20150103000000", names[0])
+
+	orphans, err = devstep.FindOrphanedContainers(clientMock, 150*time.Minute)
+	ok(t, err)
+	equals(t, 4, len(orphans))
+}
+
+func Test_FindOrphanedContainersSkipsContainersWithSessions(t *testing.T) {
+	defer useTempSessionsDir()()
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CacheDir:    "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	clientMock.ExecuteFunc = func(o *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		started <- struct{}{}
+		<-release
+		return &devstep.DockerExecResult{}, nil
+	}
+	clientMock.FindContainersFunc = func(map[string]string) ([]*devstep.DockerContainer, error) {
+		return []*devstep.DockerContainer{{
+			ID:      "cid",
+			Name:    "hack-between-execs",
+			Labels:  map[string]string{devstep.LabelProject: "/path/on/host", devstep.LabelRole: "hack"},
+			Running: true,
+			Created: time.Now().Add(-time.Hour),
+		}}, nil
+	}
+
+	errs := make(chan error)
+	go func() { errs <- project.Hack(clientMock, nil) }()
+	<-started
+
+	orphans, err := devstep.FindOrphanedContainers(clientMock, 0)
+	ok(t, err)
+	equals(t, 0, len(orphans))
+
+	release <- struct{}{}
+	ok(t, <-errs)
+
+	orphans, err = devstep.FindOrphanedContainers(clientMock, 0)
+	ok(t, err)
+	equals(t, 1, len(orphans))
+}
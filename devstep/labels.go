@@ -0,0 +1,59 @@
+package devstep
+
+// Labels applied to the containers and images created by devstep so that we
+// can find them later on
+const (
+	LabelProject    = "io.devstep.project"     // root dir of the project on the host
+	LabelRepository = "io.devstep.repository"  // repository the project images are commited to
+	LabelRole       = "io.devstep.role"        // what the container was created for
+	LabelVersion    = "io.devstep.version"     // version of devstep that created the container or image
+	LabelConfigHash = "io.devstep.config-hash" // hash of the configuration in use, see ProjectConfig.Hash
+	LabelKeep       = "io.devstep.keep"        // set on hack containers that are kept after the last session ends
+	LabelAttached   = "io.devstep.attached"    // set on hack containers that run attached to the devstep process
+
+	LabelDependencies = "io.devstep.dependencies-hash" // hash of the dependency manifests the image was built with
+	LabelManifests    = "io.devstep.manifests"         // hash of each dependency manifest, encoded as JSON
+)
+
+// Roles of the containers created for a project
+const (
+	RoleHack      = "hack"
+	RoleRun       = "run"
+	RoleBuild     = "build"
+	RoleBootstrap = "bootstrap"
+	RoleService   = "service"
+)
+
+// Labels for containers created for the project
+func (p *project) labels(role string) map[string]string {
+	labels := p.imageLabels()
+	labels[LabelRole] = role
+	// Containers inherit the labels of their images, so these are always set
+	// to avoid picking up the ones of images commited by older versions
+	labels[LabelKeep] = "false"
+	labels[LabelAttached] = "false"
+	return labels
+}
+
+// Labels for images commited for the project. Docker adds the labels of the
+// container to the image, so the ones that only make sense for containers
+// are blanked out.
+func (p *project) imageLabels() map[string]string {
+	return map[string]string{
+		LabelProject:    p.HostDir,
+		LabelRepository: p.RepositoryName,
+		LabelVersion:    Version,
+		LabelConfigHash: p.Hash(),
+		LabelRole:       "",
+		LabelKeep:       "",
+		LabelAttached:   "",
+	}
+}
+
+// Labels that identify the containers of the project created for a role
+func (p *project) roleFilter(role string) map[string]string {
+	return map[string]string{
+		LabelProject: p.HostDir,
+		LabelRole:    role,
+	}
+}
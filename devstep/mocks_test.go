@@ -1,23 +1,37 @@
 package devstep_test
 
 import (
+	"io"
+
 	"github.com/fgrehm/devstep-cli/devstep"
 )
 
 type MockClient struct {
-	ExecuteFunc                          func(*devstep.DockerExecOpts) error
+	ExecuteFunc                          func(*devstep.DockerExecOpts) (*devstep.DockerExecResult, error)
 	RunFunc                              func(*devstep.DockerRunOpts) (*devstep.DockerRunResult, error)
 	RemoveContainerFunc                  func(string) error
-	ContainerChangedFunc                 func(string) (bool, error)
-	ContainerHasExecInstancesRunningFunc func(string) bool
+	ContainerChangesFunc                 func(string) ([]*devstep.DockerChange, error)
+	ImageContentsFunc                    func(string) (*devstep.DockerContents, error)
+	ContainerContentsFunc                func(string) (*devstep.DockerContents, error)
+	ContainerHasExecInstancesRunningFunc func(string) (bool, error)
 	CommitFunc                           func(*devstep.DockerCommitOpts) error
 	RemoveImageFunc                      func(string) error
+	InspectImageFunc                     func(string) (*devstep.DockerImage, error)
+	TagImageFunc                         func(string, string, string) error
+	SaveImageFunc                        func(string, io.Writer) error
+	LoadImageFunc                        func(io.Reader) error
+	PushImageFunc                        func(string, string) error
+	PullImageFunc                        func(string, string) error
 	ListTagsFunc                         func(string) ([]string, error)
-	ListContainersFunc                   func(string) ([]string, error)
+	ListContainersFunc                   func(map[string]string) ([]string, error)
+	FindContainersFunc                   func(map[string]string) ([]*devstep.DockerContainer, error)
 	LookupContainerIDFunc                func(string) (string, error)
+	ContainerStatusFunc                  func(string) (*devstep.DockerContainerStatus, error)
+	StartContainerFunc                   func(string) error
+	StopContainerFunc                    func(string) error
 }
 
-func (c *MockClient) Execute(execOpts *devstep.DockerExecOpts) error {
+func (c *MockClient) Execute(execOpts *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
 	return c.ExecuteFunc(execOpts)
 }
 
@@ -29,11 +43,19 @@ func (c *MockClient) RemoveContainer(containerID string) error {
 	return c.RemoveContainerFunc(containerID)
 }
 
-func (c *MockClient) ContainerChanged(containerID string) (bool, error) {
-	return c.ContainerChangedFunc(containerID)
+func (c *MockClient) ContainerChanges(containerID string) ([]*devstep.DockerChange, error) {
+	return c.ContainerChangesFunc(containerID)
+}
+
+func (c *MockClient) ImageContents(imageName string) (*devstep.DockerContents, error) {
+	return c.ImageContentsFunc(imageName)
 }
 
-func (c *MockClient) ContainerHasExecInstancesRunning(containerID string) bool {
+func (c *MockClient) ContainerContents(containerID string) (*devstep.DockerContents, error) {
+	return c.ContainerContentsFunc(containerID)
+}
+
+func (c *MockClient) ContainerHasExecInstancesRunning(containerID string) (bool, error) {
 	return c.ContainerHasExecInstancesRunningFunc(containerID)
 }
 
@@ -45,31 +67,95 @@ func (c *MockClient) RemoveImage(imageName string) error {
 	return c.RemoveImageFunc(imageName)
 }
 
+func (c *MockClient) InspectImage(imageName string) (*devstep.DockerImage, error) {
+	return c.InspectImageFunc(imageName)
+}
+
+func (c *MockClient) TagImage(imageName, repositoryName, tag string) error {
+	return c.TagImageFunc(imageName, repositoryName, tag)
+}
+
+func (c *MockClient) SaveImage(imageName string, output io.Writer) error {
+	return c.SaveImageFunc(imageName, output)
+}
+
+func (c *MockClient) LoadImage(input io.Reader) error {
+	return c.LoadImageFunc(input)
+}
+
+func (c *MockClient) PushImage(imageName, tag string) error {
+	return c.PushImageFunc(imageName, tag)
+}
+
+func (c *MockClient) PullImage(imageName, tag string) error {
+	return c.PullImageFunc(imageName, tag)
+}
+
 func (c *MockClient) ListTags(repositoryName string) ([]string, error) {
 	return c.ListTagsFunc(repositoryName)
 }
 
-func (c *MockClient) ListContainers(repositoryName string) ([]string, error) {
-	return c.ListContainersFunc(repositoryName)
+func (c *MockClient) ListContainers(labels map[string]string) ([]string, error) {
+	return c.ListContainersFunc(labels)
+}
+
+func (c *MockClient) FindContainers(labels map[string]string) ([]*devstep.DockerContainer, error) {
+	return c.FindContainersFunc(labels)
 }
 
 func (c *MockClient) LookupContainerID(containerName string) (string, error) {
 	return c.LookupContainerIDFunc(containerName)
 }
 
+func (c *MockClient) ContainerStatus(containerName string) (*devstep.DockerContainerStatus, error) {
+	return c.ContainerStatusFunc(containerName)
+}
+
+func (c *MockClient) StartContainer(containerID string) error {
+	return c.StartContainerFunc(containerID)
+}
+
+func (c *MockClient) StopContainer(containerID string) error {
+	return c.StopContainerFunc(containerID)
+}
+
 func NewMockClient() *MockClient {
 	return &MockClient{
 		ListTagsFunc: func(repositoryName string) ([]string, error) {
 			return []string{}, nil
 		},
-		ListContainersFunc: func(repositoryName string) ([]string, error) {
+		ListContainersFunc: func(labels map[string]string) ([]string, error) {
 			return []string{}, nil
 		},
+		InspectImageFunc: func(imageName string) (*devstep.DockerImage, error) {
+			return &devstep.DockerImage{ID: imageName}, nil
+		},
+		TagImageFunc: func(imageName, repositoryName, tag string) error {
+			return nil
+		},
+		RemoveImageFunc: func(imageName string) error {
+			return nil
+		},
+		PushImageFunc: func(imageName, tag string) error {
+			return nil
+		},
+		PullImageFunc: func(imageName, tag string) error {
+			return nil
+		},
+		FindContainersFunc: func(labels map[string]string) ([]*devstep.DockerContainer, error) {
+			return []*devstep.DockerContainer{}, nil
+		},
+		ExecuteFunc: func(execOpts *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+			return &devstep.DockerExecResult{}, nil
+		},
+		ContainerHasExecInstancesRunningFunc: func(containerID string) (bool, error) {
+			return false, nil
+		},
 		RunFunc: func(runOpts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
 			return &devstep.DockerRunResult{}, nil
 		},
-		ContainerChangedFunc: func(containerID string) (bool, error) {
-			return true, nil
+		ContainerChangesFunc: func(containerID string) ([]*devstep.DockerChange, error) {
+			return []*devstep.DockerChange{{Path: "/home/devstep/.bashrc", Kind: devstep.ChangeAdded}}, nil
 		},
 		CommitFunc: func(commitOpts *devstep.DockerCommitOpts) error {
 			return nil
@@ -77,5 +163,14 @@ func NewMockClient() *MockClient {
 		RemoveContainerFunc: func(containerID string) error {
 			return nil
 		},
+		ContainerStatusFunc: func(containerName string) (*devstep.DockerContainerStatus, error) {
+			return nil, nil
+		},
+		StartContainerFunc: func(containerID string) error {
+			return nil
+		},
+		StopContainerFunc: func(containerID string) error {
+			return nil
+		},
 	}
 }
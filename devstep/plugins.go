@@ -12,8 +12,9 @@ type PluginRuntime interface {
 }
 
 type pluginRuntime struct {
-	projectCfg *ProjectConfig
-	vm         *otto.Otto
+	projectCfg    *ProjectConfig
+	vm            *otto.Otto
+	currentPlugin string // path of the plugin whose callback is being run
 }
 
 func NewPluginRuntime(projectCfg *ProjectConfig) PluginRuntime {
@@ -37,6 +38,11 @@ func NewPluginRuntime(projectCfg *ProjectConfig) PluginRuntime {
 		panic("Error registering _configWrapper\n " + err.Error())
 	}
 
+	err = runtime.vm.Set("_setCurrentPlugin", runtime.setCurrentPlugin)
+	if err != nil {
+		panic("Error registering _setCurrentPlugin\n " + err.Error())
+	}
+
 	_, err = runtime.vm.Run(initPluginJsEnvironment)
 	if err != nil {
 		panic("Error initializing plugin environment:\n " + err.Error())
@@ -64,6 +70,11 @@ func (r *pluginRuntime) Load(pluginPath string) error {
 		panic("Error setting current plugin path _DEVSTEP_ADD_VOLUME\n " + err.Error())
 	}
 
+	err = r.vm.Set("_currentPluginFile", pluginPath)
+	if err != nil {
+		panic("Error setting current plugin file\n " + err.Error())
+	}
+
 	_, err = r.vm.Run(src)
 	return err
 }
@@ -74,22 +85,34 @@ devstep._events = { configLoaded: [] };
 devstep.trigger = function(eventName) {
 	var events = devstep._events[eventName];
 	for (var i = 0; i < events.length; i++) {
-		events[i](_configWrapper);
+		_setCurrentPlugin(events[i].plugin);
+		events[i].callback(_configWrapper);
 	}
 };
 devstep.on = function(eventName, cb) {
-	devstep._events[eventName].push(cb);
+	devstep._events[eventName].push({ callback: cb, plugin: _currentPluginFile });
 };
 `
 
+func (r *pluginRuntime) setCurrentPlugin(call otto.FunctionCall) otto.Value {
+	r.currentPlugin = call.Argument(0).String()
+	return otto.UndefinedValue()
+}
+
+func (r *pluginRuntime) source() *ConfigSource {
+	return &ConfigSource{Type: ConfigSourcePlugin, Plugin: r.currentPlugin}
+}
+
 func (r *pluginRuntime) addVolume(call otto.FunctionCall) otto.Value {
 	defaults := r.projectCfg.Defaults
+	r.projectCfg.SetSource(indexedKey("volumes", len(defaults.Volumes)), r.source())
 	defaults.Volumes = append(defaults.Volumes, call.Argument(0).String())
 	return call.This
 }
 
 func (r *pluginRuntime) addLink(call otto.FunctionCall) otto.Value {
 	defaults := r.projectCfg.Defaults
+	r.projectCfg.SetSource(indexedKey("links", len(defaults.Links)), r.source())
 	defaults.Links = append(defaults.Links, call.Argument(0).String())
 	return call.This
 }
@@ -97,5 +120,6 @@ func (r *pluginRuntime) addLink(call otto.FunctionCall) otto.Value {
 func (r *pluginRuntime) setEnv(call otto.FunctionCall) otto.Value {
 	defaults := r.projectCfg.Defaults
 	defaults.Env[call.Argument(0).String()] = call.Argument(1).String()
+	r.projectCfg.SetSource("environment."+call.Argument(0).String(), r.source())
 	return call.This
 }
@@ -0,0 +1,101 @@
+package devstep
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A container port published on the host, a blank host port means that
+// docker will pick a random one
+type PortMapping struct {
+	HostIP        string
+	HostPort      string
+	ContainerPort string
+	Protocol      string // tcp or udp
+}
+
+// The container port along with its protocol, as used by the Docker API
+func (m PortMapping) Port() string {
+	return m.ContainerPort + "/" + m.Protocol
+}
+
+func (m PortMapping) String() string {
+	host := m.HostPort
+	if m.HostIP != "" {
+		host = m.HostIP + ":" + host
+	}
+	return host + "->" + m.Port()
+}
+
+var portSpec = regexp.MustCompile(`^(?:(?:([^:]*):)?(\d*(?:-\d+)?):)?(\d+(?:-\d+)?)(?:/(tcp|udp))?$`)
+
+// Parses ports in the `[[ip:][hostPort]:]containerPort[/protocol]` format,
+// ranges like `8000-8010:8000-8010` get expanded to one mapping per port
+func ParsePorts(spec string) ([]PortMapping, error) {
+	invalid := errors.New("Invalid port '" + spec + "', expected '[[ip:][host-port]:]container-port[/tcp|udp]'")
+
+	match := portSpec.FindStringSubmatch(spec)
+	if match == nil {
+		return nil, invalid
+	}
+	hostIP, hostPorts, containerPorts, protocol := match[1], match[2], match[3], match[4]
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	containerStart, containerEnd, err := parsePortRange(containerPorts)
+	if err != nil {
+		return nil, invalid
+	}
+	hostStart, hostEnd := 0, 0
+	if hostPorts != "" {
+		if hostStart, hostEnd, err = parsePortRange(hostPorts); err != nil {
+			return nil, invalid
+		}
+		if hostEnd-hostStart != containerEnd-containerStart {
+			return nil, errors.New("Invalid port '" + spec + "', host and container port ranges must have the same size")
+		}
+	}
+
+	mappings := []PortMapping{}
+	for i := 0; i <= containerEnd-containerStart; i++ {
+		mapping := PortMapping{
+			HostIP:        hostIP,
+			ContainerPort: strconv.Itoa(containerStart + i),
+			Protocol:      protocol,
+		}
+		if hostPorts != "" {
+			mapping.HostPort = strconv.Itoa(hostStart + i)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+func parsePortRange(ports string) (int, int, error) {
+	startAndEnd := strings.SplitN(ports, "-", 2)
+	start, err := parsePort(startAndEnd[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end := start
+	if len(startAndEnd) == 2 {
+		if end, err = parsePort(startAndEnd[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if end < start {
+		return 0, 0, errors.New("Invalid port range " + ports)
+	}
+	return start, end, nil
+}
+
+func parsePort(port string) (int, error) {
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		return 0, errors.New("Invalid port " + port)
+	}
+	return number, nil
+}
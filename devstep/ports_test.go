@@ -0,0 +1,54 @@
+package devstep_test
+
+import (
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_ParsePorts(t *testing.T) {
+	examples := map[string][]devstep.PortMapping{
+		"8080:80": {
+			{HostPort: "8080", ContainerPort: "80", Protocol: "tcp"},
+		},
+		"80": {
+			{ContainerPort: "80", Protocol: "tcp"},
+		},
+		"127.0.0.1:8080:80/tcp": {
+			{HostIP: "127.0.0.1", HostPort: "8080", ContainerPort: "80", Protocol: "tcp"},
+		},
+		"127.0.0.1::80": {
+			{HostIP: "127.0.0.1", ContainerPort: "80", Protocol: "tcp"},
+		},
+		"5353:53/udp": {
+			{HostPort: "5353", ContainerPort: "53", Protocol: "udp"},
+		},
+		"8000-8001:3000-3001": {
+			{HostPort: "8000", ContainerPort: "3000", Protocol: "tcp"},
+			{HostPort: "8001", ContainerPort: "3001", Protocol: "tcp"},
+		},
+		"3000-3001/udp": {
+			{ContainerPort: "3000", Protocol: "udp"},
+			{ContainerPort: "3001", Protocol: "udp"},
+		},
+	}
+
+	for spec, expected := range examples {
+		mappings, err := devstep.ParsePorts(spec)
+		ok(t, err)
+		equals(t, expected, mappings)
+	}
+}
+
+func Test_ParsePortsWithInvalidSpecs(t *testing.T) {
+	invalid := []string{"", "abc", "80:", "8080:80/sctp", "70000", "0:80", "8000-8002:3000-3001", "3001-3000", "a:b:c:80"}
+	for _, spec := range invalid {
+		_, err := devstep.ParsePorts(spec)
+		assert(t, err != nil, "Port was accepted: "+spec)
+	}
+}
+
+func Test_PortMappingString(t *testing.T) {
+	mapping := devstep.PortMapping{HostIP: "0.0.0.0", HostPort: "32768", ContainerPort: "80", Protocol: "tcp"}
+	equals(t, "0.0.0.0:32768->80/tcp", mapping.String())
+}
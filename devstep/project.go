@@ -3,6 +3,13 @@ package devstep
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/kardianos/osext"
@@ -13,26 +20,67 @@ import (
 type Project interface {
 	Config() *ProjectConfig
 	Build(DockerClient, *DockerRunOpts) error
-	Commit(DockerClient, string) error
+	Commit(DockerClient, string, *CommitOpts) error
 	Bootstrap(DockerClient, *DockerRunOpts) error
 	Clean(DockerClient) error
+	Prune(DockerClient, bool) ([]string, error)
+	Snapshots(DockerClient) ([]*Snapshot, error)
+	Rollback(DockerClient, string) error
+	Export(DockerClient, io.Writer) error
+	Import(DockerClient, string) error
+	Push(DockerClient) error
+	Pull(DockerClient) error
+	Status(DockerClient) (*ProjectStatus, error)
+	Diff(DockerClient, string) (*ContainerDiff, error)
+	CompareSnapshots(DockerClient, string, string) (*SnapshotDiff, error)
 	Hack(DockerClient, *DockerRunOpts) error
 	Run(DockerClient, *DockerRunOpts) (*DockerRunResult, error)
-	Exec(DockerClient, []string) error
+	Exec(DockerClient, []string) (*DockerExecResult, error)
+	StartServices(DockerClient) error
+	StopServices(DockerClient, bool) error
+	ServicesStatus(DockerClient) ([]*ServiceStatus, error)
 }
 
 // Project specific configuration, usually parsed from an yaml file
 type ProjectConfig struct {
-	SourceImage    string         // image used when starting environments from scratch
-	BaseImage      string         // starting point for the project
-	RepositoryName string         // name of the docker repository this project should be commited
-	HostDir        string         // root directory of the project on the host machine
-	GuestDir       string         // directory where the project sources will be mounted on the container
-	CacheDir       string         // a directory on the host machine were we can place downloaded packages
-	Defaults       *DockerRunOpts // default options passed on to docker for all commands
-	HackOpts       *DockerRunOpts // `devstep hack` specific options passed to the container
+	SourceImage       string                   // image used when starting environments from scratch
+	BaseImage         string                   // starting point for the project
+	RepositoryName    string                   // name of the docker repository this project should be commited
+	Registry          string                   // registry host images are pushed to and pulled from, the Docker Hub when blank
+	HostDir           string                   // root directory of the project on the host machine
+	GuestDir          string                   // directory where the project sources will be mounted on the container
+	CurrentDir        string                   // directory devstep was run from, relative to the host dir
+	NoTTY             bool                     // run build, one off containers and exec without a pseudo terminal
+	CacheDir          string                   // a directory on the host machine were we can place downloaded packages
+	Profile           string                   // name of the configuration profile in use, if any
+	Defaults          *DockerRunOpts           // default options passed on to docker for all commands
+	HackOpts          *DockerRunOpts           // `devstep hack` specific options passed to the container
+	BuildOpts         *DockerRunOpts           // `devstep build` specific options passed to the container
+	BootstrapOpts     *DockerRunOpts           // `devstep bootstrap` specific options passed to the container
+	RunOpts           *DockerRunOpts           // `devstep run` specific options passed to the container
+	ExecOpts          *DockerRunOpts           // `devstep exec` specific options, only env vars are supported
+	Provision         [][]string               // custom commands executed on the build container before commiting
+	Services          []*ServiceConfig         // containers started and linked before the project containers
+	Retention         *RetentionPolicy         // how many timestamped images are kept after each commit
+	KeepHackContainer bool                     // keep the hack container running after the last session ends
+	CacheKeys         []string                 // dependency manifests checked before building, DefaultCacheKeys when nil
+	ForceBuild        bool                     // build even if the dependency manifests did not change
+	NoCommit          bool                     // keep build containers around instead of commiting them, see Diff
+	IgnoreChanges     []string                 // paths that don't count as changes to build containers, DefaultIgnoredChanges when nil
+	Sources           map[string]*ConfigSource // where each setting came from, see Explain
 }
 
+// Options for commiting a running container with `devstep commit`
+type CommitOpts struct {
+	Tag     string // commit a named snapshot instead of `latest` and a timestamp
+	Message string // commit message
+	Author  string // author of the commit
+	Pause   *bool  // whether the container is paused while commiting, Docker's default is to pause it
+}
+
+// Tags follow the same rules as Docker's
+var validTag = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
 // An implementation of a Project.
 type project struct {
 	*ProjectConfig
@@ -47,6 +95,18 @@ func NewProject(config *ProjectConfig) (Project, error) {
 	if project.HackOpts == nil {
 		project.HackOpts = &DockerRunOpts{Env: make(map[string]string)}
 	}
+	if project.BuildOpts == nil {
+		project.BuildOpts = &DockerRunOpts{Env: make(map[string]string)}
+	}
+	if project.BootstrapOpts == nil {
+		project.BootstrapOpts = &DockerRunOpts{Env: make(map[string]string)}
+	}
+	if project.RunOpts == nil {
+		project.RunOpts = &DockerRunOpts{Env: make(map[string]string)}
+	}
+	if project.ExecOpts == nil {
+		project.ExecOpts = &DockerRunOpts{Env: make(map[string]string)}
+	}
 	return project, nil
 }
 
@@ -54,41 +114,81 @@ func (p *project) Config() *ProjectConfig {
 	return p.ProjectConfig
 }
 
-// Build the project and commit it to an image
+// Build the project and commit it to an image. Builds are skipped when the
+// dependency manifests did not change since the last one and start from the
+// source image when manifests got added or removed.
 func (p *project) Build(client DockerClient, cliOpts *DockerRunOpts) error {
-	fmt.Printf("==> Building project from '%s'\n", p.BaseImage)
-
-	result, err := p.buildWithCommand(client, cliOpts, []string{"/opt/devstep/bin/build-project", p.GuestDir})
+	cache, err := p.dependencyState()
 	if err != nil {
 		return err
 	}
 
+	image := p.BaseImage
+	if cache != nil && !p.ForceBuild && image != p.SourceImage {
+		last, err := p.lastBuildCache(client)
+		if err != nil {
+			return err
+		}
+		if last != nil && last.Key == cache.Key {
+			fmt.Println("==> Dependencies did not change since the last build, skipping (use --force to build anyway)")
+			return nil
+		}
+		if last != nil && cache.changedDrastically(last) {
+			fmt.Println("==> Dependency manifests were added or removed, rebuilding from scratch")
+			image = p.SourceImage
+		}
+	}
+
+	fmt.Printf("==> Building project from '%s'\n", image)
+
+	result, err := p.buildWithCommand(client, RoleBuild, image, cache.labels(), p.BuildOpts, cliOpts, p.withProvisioning([]string{"/opt/devstep/bin/build-project", p.GuestDir}))
+	if err != nil || p.NoCommit {
+		return err
+	}
+
 	fmt.Println("==> Removing container used for build")
 	return client.RemoveContainer(result.ContainerID)
 }
 
-// Commit a container that is running
-func (p *project) Commit(client DockerClient, containerName string) error {
+// Commit a container that is running, named snapshots are commited on their
+// own and don't change the image the project is based on
+func (p *project) Commit(client DockerClient, containerName string, opts *CommitOpts) error {
+	if opts == nil {
+		opts = &CommitOpts{}
+	}
+	if opts.Tag != "" && !validTag.MatchString(opts.Tag) {
+		return errors.New("Invalid tag '" + opts.Tag + "'")
+	}
+
 	containerID, err := client.LookupContainerID(containerName)
 
 	if err != nil {
 		return err
 	}
 
-	if err = p.commit(client, containerID, "latest"); err != nil {
+	if opts.Tag != "" {
+		return p.commitWithOpts(client, containerID, opts.Tag, opts, nil)
+	}
+
+	if err = p.commitWithOpts(client, containerID, "latest", opts, nil); err != nil {
+		return err
+	}
+
+	tag := time.Now().Local().Format(snapshotTagFormat)
+	if err = p.commitWithOpts(client, containerID, tag, opts, nil); err != nil {
 		return err
 	}
 
-	tag := time.Now().Local().Format("20060102150405")
-	return p.commit(client, containerID, tag)
+	p.enforceRetention(client)
+	return nil
 }
 
 // Start a hacking session and commit it to an image if all goes well
 func (p *project) Bootstrap(client DockerClient, cliOpts *DockerRunOpts) error {
 	fmt.Printf("==> Creating container based on '%s'\n", p.BaseImage)
 
-	result, err := p.buildWithCommand(client, cliOpts, []string{"bash"})
-	if err != nil {
+	result, err := p.buildWithCommand(client, RoleBootstrap, p.BaseImage, nil, p.BootstrapOpts, cliOpts, []string{"bash"})
+	if err != nil || p.NoCommit {
 		return err
 	}
 
@@ -96,78 +196,211 @@ func (p *project) Bootstrap(client DockerClient, cliOpts *DockerRunOpts) error {
 	return client.RemoveContainer(result.ContainerID)
 }
 
-// Starts a hacking session on the project
+// Starts a hacking session on the project. Sessions share a single container
+// which is removed once the last one ends, unless KeepHackContainer is set.
 func (p *project) Hack(client DockerClient, cliHackOpts *DockerRunOpts) error {
-	if p.SourceImage != p.BaseImage {
-		containerID := ""
+	p.pullFirstEnvironment(client)
 
-		containers, err := client.ListContainers(p.BaseImage)
-		if err != nil {
-			return err
-		}
+	if p.SourceImage == p.BaseImage {
+		opts := p.HackOpts.Merge(cliHackOpts, &DockerRunOpts{
+			Cmd: []string{"/opt/devstep/bin/hack"},
+			// The terminal is already in raw mode when the container starts
+			OnStart: func(result *DockerRunResult) {
+				fmt.Print(strings.Replace(formatPorts(result.Ports), "\n", "\r\n", -1))
+			},
+		})
 
-		if len(containers) == 0 {
-			log.Debug("==> No containers have been created for '%s', will start a new one\n", p.BaseImage)
+		_, err := p.run(client, RoleHack, opts, nil)
 
-			result, err := p.startContainer(client, cliHackOpts)
-			if err != nil {
-				return err
-			}
+		return err
+	}
 
-			containerID = result.ContainerID
+	sessions := newSessionManager(p.HostDir)
+	session, created, err := p.startSession(client, sessions, cliHackOpts)
+	if err != nil {
+		return err
+	}
 
-			log.Debug("STARTED: %+v", result)
+	// Sessions that reuse the container would otherwise start on the
+	// directory of the first one
+	cmd, workdir := []string{"bash"}, p.workdir()
+	if created {
+		cmd, workdir = []string{"/opt/devstep/bin/hack"}, ""
+	}
 
-			err = p.Exec(client, []string{"/opt/devstep/bin/hack"})
-		} else {
-			containerID = containers[0]
-			err = p.Exec(client, []string{"bash"})
+	// Stop the container when interrupted so that the session ends and the
+	// container gets cleaned up below, unless other sessions are using it
+	stopHandling := handleSignals(func(sig os.Signal) {
+		p.stopSessionContainer(client, sessions, session, sig)
+	})
+	_, err = p.execOn(client, session.ContainerID, cmd, nil, workdir, false)
+	if sig := stopHandling(); sig != nil && err == nil {
+		err = &InterruptedError{Signal: sig}
+	}
+
+	if endErr := p.endSession(client, sessions, session); endErr != nil {
+		if err == nil {
+			return endErr
 		}
+		fmt.Println(endErr)
+	}
+	return err
+}
+
+// Registers a session for the running hack container, starting a new one
+// if needed. Returns whether the container was created.
+func (p *project) startSession(client DockerClient, sessions *sessionManager, cliHackOpts *DockerRunOpts) (*hackSession, bool, error) {
+	unlock, err := sessions.lock()
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	containers, err := client.ListContainers(p.roleFilter(RoleHack))
+	if err != nil {
+		return nil, false, err
+	}
+
+	created := false
+	containerID := ""
+	if len(containers) > 0 {
+		containerID = containers[0]
+		log.Debug("Reusing container '%s'", containerID)
+	} else {
+		log.Debug("==> No containers are running for '%s', will start a new one\n", p.HostDir)
 
+		result, err := p.startContainer(client, cliHackOpts)
 		if err != nil {
-			// Check how many exec instances we have in place and remove the container
-			// if it is the last one
-			if !client.ContainerHasExecInstancesRunning(containerID) {
-				fmt.Printf("Removing container: %+v\n", containerID)
-				client.RemoveContainer(containerID)
-			} else {
-				fmt.Printf("Skipping container removal: %s\n", containerID)
-			}
-			return err
+			return nil, false, err
 		}
+		log.Debug("STARTED: %+v", result)
+		printPorts(result.Ports)
+
+		containerID = result.ContainerID
+		created = true
+	}
 
-		// Check how many exec instances we have in place and remove the container
-		// if it is the last one
-		if !client.ContainerHasExecInstancesRunning(containerID) {
-			fmt.Printf("Removing container: %+v\n", containerID)
+	session, err := sessions.register(containerID)
+	if err != nil {
+		if created {
 			client.RemoveContainer(containerID)
-		} else {
-			fmt.Printf("Skipping container removal: %s\n", containerID)
 		}
+		return nil, false, err
+	}
+	return session, created, nil
+}
 
+// Unregisters the session and removes the container if no other sessions
+// are using it
+func (p *project) endSession(client DockerClient, sessions *sessionManager, session *hackSession) error {
+	unlock, err := sessions.lock()
+	if err != nil {
 		return err
+	}
+	defer unlock()
 
-	} else {
-		opts := p.HackOpts.Merge(cliHackOpts, &DockerRunOpts{
-			Cmd: []string{"/opt/devstep/bin/hack"},
-		})
+	if err = sessions.unregister(session); err != nil {
+		return err
+	}
+
+	containerID := session.ContainerID
+	keep, err := p.keepsContainer(client, containerID)
+	if err != nil {
+		return err
+	}
+	if keep {
+		fmt.Printf("==> Keeping container running: %s\n", containerID)
+		return nil
+	}
 
-		_, err := p.Run(client, opts)
+	others, err := sessions.activeSessions(containerID)
+	if err != nil {
+		return err
+	}
+	if others > 0 {
+		fmt.Printf("Skipping container removal, %d other sessions are attached: %s\n", others, containerID)
+		return nil
+	}
 
+	// Exec instances that were not started by `devstep hack` (like the ones
+	// from `devstep exec`) are not registered as sessions
+	execsRunning, err := client.ContainerHasExecInstancesRunning(containerID)
+	if err != nil {
 		return err
 	}
+	if execsRunning {
+		fmt.Printf("Skipping container removal: %s\n", containerID)
+		return nil
+	}
+
+	fmt.Printf("Removing container: %+v\n", containerID)
+	sessions.forget(containerID)
+	return client.RemoveContainer(containerID)
+}
+
+// Containers started with KeepHackContainer are labeled so that they are
+// kept no matter which session ends last
+func (p *project) keepsContainer(client DockerClient, containerID string) (bool, error) {
+	if p.KeepHackContainer {
+		return true, nil
+	}
+
+	filters := p.roleFilter(RoleHack)
+	filters[LabelKeep] = "true"
+	containers, err := client.FindContainers(filters)
+	if err != nil {
+		return false, err
+	}
+	for _, container := range containers {
+		if container.ID == containerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *project) stopSessionContainer(client DockerClient, sessions *sessionManager, session *hackSession, sig os.Signal) {
+	unlock, err := sessions.lock()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer unlock()
+
+	// Our own session is still registered at this point
+	if active, err := sessions.activeSessions(session.ContainerID); err != nil || active > 1 {
+		fmt.Printf("\n==> Received %s, leaving container running for the other sessions\n", sig)
+		return
+	}
+	fmt.Printf("\n==> Received %s, stopping container\n", sig)
+	client.StopContainer(session.ContainerID)
 }
 
 func (p *project) Run(client DockerClient, cliRunOpts *DockerRunOpts) (*DockerRunResult, error) {
-	opts := p.Defaults.Merge(cliRunOpts, &DockerRunOpts{
+	return p.run(client, RoleRun, p.RunOpts, cliRunOpts)
+}
+
+func (p *project) run(client DockerClient, role string, commandOpts, cliOpts *DockerRunOpts) (*DockerRunResult, error) {
+	serviceLinks, err := p.startServices(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// These containers live as long as the devstep process is attached to them
+	labels := p.labels(role)
+	labels[LabelAttached] = "true"
+
+	opts := p.mergeOpts(commandOpts, cliOpts, &DockerRunOpts{
 		Image:      p.BaseImage,
 		AutoRemove: true,
-		Pty:        true,
-		Workdir:    p.GuestDir,
+		Pty:        !p.NoTTY,
+		Workdir:    p.workdir(),
 		Volumes: []string{
 			p.HostDir + ":" + p.GuestDir,
 			p.CacheDir + ":/home/devstep/cache",
 		},
+		Links:  serviceLinks,
+		Labels: labels,
 	})
 
 	fmt.Printf("==> Creating container using '%s'\n", p.BaseImage)
@@ -175,23 +408,48 @@ func (p *project) Run(client DockerClient, cliRunOpts *DockerRunOpts) (*DockerRu
 	return client.Run(opts)
 }
 
-func (p *project) Exec(client DockerClient, cmd []string) error {
-	containers, err := client.ListContainers(p.BaseImage)
+func (p *project) Exec(client DockerClient, cmd []string) (*DockerExecResult, error) {
+	return p.exec(client, cmd, p.ExecOpts.Env, p.NoTTY)
+}
+
+func (p *project) exec(client DockerClient, cmd []string, env map[string]string, noTTY bool) (*DockerExecResult, error) {
+	containers, err := client.ListContainers(p.roleFilter(RoleHack))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(containers) == 0 {
-		return errors.New("No containers found to execute the command.")
+		return nil, errors.New("No containers found to execute the command.")
 	}
 
+	return p.execOn(client, containers[0], cmd, env, p.workdir(), noTTY)
+}
+
+func (p *project) execOn(client DockerClient, containerID string, cmd []string, env map[string]string, workdir string, noTTY bool) (*DockerExecResult, error) {
 	cmd = append([]string{"/opt/devstep/bin/exec-entrypoint"}, cmd...)
 
-	log.Debug("==> Executing %v on '%s'\n", cmd, containers[0])
+	// Exec instances start on the working dir the container was created
+	// with, which might not be the one devstep was run from
+	if workdir != "" {
+		cmd = append([]string{"bash", "-c", "cd " + shellQuote(workdir) + ` && exec "$@"`, "bash"}, cmd...)
+	}
+
+	// Docker does not support setting env vars for exec instances, so we rely
+	// on `env` to set them before the command gets executed
+	if len(env) > 0 {
+		envCmd := []string{"env"}
+		for _, k := range sortedKeys(env) {
+			envCmd = append(envCmd, k+"="+env[k])
+		}
+		cmd = append(envCmd, cmd...)
+	}
+
+	log.Debug("==> Executing %v on '%s'\n", cmd, containerID)
 	return client.Execute(&DockerExecOpts{
-		ContainerID: containers[0],
+		ContainerID: containerID,
 		Cmd:         cmd,
 		User:        "developer",
+		NoTTY:       noTTY,
 	})
 }
 
@@ -213,12 +471,81 @@ func (p *project) Clean(client DockerClient) error {
 	return nil
 }
 
-func (p *project) commit(client DockerClient, containerID, tag string) error {
+// Removes the timestamped images that are not kept by the retention policy,
+// returning their names. The image `latest` points to is never removed.
+func (p *project) Prune(client DockerClient, dryRun bool) ([]string, error) {
+	pruned := []string{}
+	if p.Retention.Empty() {
+		return pruned, nil
+	}
+
+	tags, err := client.ListTags(p.RepositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := client.InspectImage(p.RepositoryName + ":latest")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range p.Retention.prunableTags(tags, time.Now()) {
+		image := p.RepositoryName + ":" + tag
+		if latest != nil {
+			info, err := client.InspectImage(image)
+			if err != nil {
+				return pruned, err
+			}
+			if info != nil && info.ID == latest.ID {
+				log.Info("Keeping '%s' since it is the latest image", image)
+				continue
+			}
+		}
+
+		if dryRun {
+			fmt.Printf("==> Would remove '%s'\n", image)
+		} else {
+			fmt.Printf("==> Removing '%s'\n", image)
+			if err = client.RemoveImage(image); err != nil {
+				return pruned, errors.New("Error removing image:\n  " + err.Error())
+			}
+		}
+		pruned = append(pruned, image)
+	}
+
+	return pruned, nil
+}
+
+// Prunes old images after commits, failing to do so is not fatal as the
+// commit itself went fine
+func (p *project) enforceRetention(client DockerClient) {
+	if p.Retention.Empty() {
+		return
+	}
+	fmt.Printf("==> Pruning images (%s)\n", p.Retention)
+	if _, err := p.Prune(client, false); err != nil {
+		fmt.Printf("==> Error pruning images, run `devstep prune` to try again:\n  %s\n", err)
+	}
+}
+
+func (p *project) commit(client DockerClient, containerID, tag string, extraLabels map[string]string) error {
+	return p.commitWithOpts(client, containerID, tag, &CommitOpts{}, extraLabels)
+}
+
+func (p *project) commitWithOpts(client DockerClient, containerID, tag string, opts *CommitOpts, extraLabels map[string]string) error {
 	fmt.Printf("==> Commiting container to '%s:%s'\n", p.RepositoryName, tag)
+	labels := p.imageLabels()
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
 	err := client.Commit(&DockerCommitOpts{
 		ContainerID:    containerID,
 		RepositoryName: p.RepositoryName,
 		Tag:            tag,
+		Message:        opts.Message,
+		Author:         opts.Author,
+		Pause:          opts.Pause,
+		Labels:         labels,
 	})
 	if err != nil {
 		return errors.New("Error commiting container:\n  " + err.Error())
@@ -233,19 +560,31 @@ func (p *project) startContainer(client DockerClient, cliOpts *DockerRunOpts) (*
 		return nil, err
 	}
 
-	opts := p.Defaults.Merge(cliOpts, &DockerRunOpts{
+	serviceLinks, err := p.startServices(client)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := p.labels(RoleHack)
+	if p.KeepHackContainer {
+		labels[LabelKeep] = "true"
+	}
+
+	opts := p.mergeOpts(p.HackOpts, cliOpts, &DockerRunOpts{
 		Image:      p.BaseImage,
 		Detach:     true,
 		AutoRemove: false,
 		Pty:        true,
 		Cmd:        []string{"--"},
-		Workdir:    p.GuestDir,
+		Workdir:    p.workdir(),
 		Volumes: []string{
 			p.HostDir + ":" + p.GuestDir,
 			p.CacheDir + ":/home/devstep/cache",
 			executable + ":/home/devstep/bin/devstep",
 			"/var/run/docker.sock:/var/run/docker.sock",
 		},
+		Links:  serviceLinks,
+		Labels: labels,
 	})
 
 	result, err := client.Run(opts)
@@ -261,24 +600,37 @@ func (p *project) startContainer(client DockerClient, cliOpts *DockerRunOpts) (*
 	return result, nil
 }
 
-func (p *project) buildWithCommand(client DockerClient, cliOpts *DockerRunOpts, cmd []string) (*DockerRunResult, error) {
-	opts := p.Defaults.Merge(cliOpts, &DockerRunOpts{
-		Image:      p.BaseImage,
+// Runs the command on a container based on the image and commits it, along
+// with the extra labels, if anything changed
+func (p *project) buildWithCommand(client DockerClient, role, image string, commitLabels map[string]string, commandOpts, cliOpts *DockerRunOpts, cmd []string) (*DockerRunResult, error) {
+	serviceLinks, err := p.startServices(client)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := p.mergeOpts(commandOpts, cliOpts, &DockerRunOpts{
+		Image:      image,
 		AutoRemove: false,
-		Pty:        true,
+		Pty:        !p.NoTTY,
 		Cmd:        cmd,
 		Workdir:    p.GuestDir,
 		Volumes: []string{
 			p.HostDir + ":" + p.GuestDir,
 			p.CacheDir + ":/home/devstep/cache",
 		},
+		Links:  serviceLinks,
+		Labels: p.labels(role),
 	})
 
 	result, err := client.Run(opts)
 	log.Debug("Docker run result: %+v", result)
 
 	if err != nil {
-		// TODO: Write test for this behavior
+		// Containers that will not be commited are removed right away, including
+		// the ones that got interrupted as they might be half built
+		if _, interrupted := err.(*InterruptedError); interrupted {
+			fmt.Println("==> Build interrupted, skipping image commit")
+		}
 		if result != nil && result.ContainerID != "" {
 			client.RemoveContainer(result.ContainerID)
 		}
@@ -291,16 +643,32 @@ func (p *project) buildWithCommand(client DockerClient, cliOpts *DockerRunOpts,
 		return result, errors.New("Container exited with status != 0, skipping image commit.")
 	}
 
-	if changed, err := client.ContainerChanged(result.ContainerID); err != nil {
+	if p.NoCommit {
+		fmt.Printf("==> Keeping container '%s' without commiting it, use 'devstep diff' to review its changes\n", result.ContainerID)
+		return result, nil
+	}
+
+	if diff, err := p.containerDiff(client, result.ContainerID); err != nil {
+		client.RemoveContainer(result.ContainerID)
 		return result, err
 
-	} else if changed {
-		if err = p.commit(client, result.ContainerID, "latest"); err != nil {
+	} else if len(diff.Changes) > 0 {
+		if err = p.commit(client, result.ContainerID, "latest", commitLabels); err != nil {
+			return result, err
+		}
+
+		tag := time.Now().Local().Format(snapshotTagFormat)
+		if err = p.commit(client, result.ContainerID, tag, commitLabels); err != nil {
 			return result, err
 		}
 
-		tag := time.Now().Local().Format("20060102150405")
-		if err = p.commit(client, result.ContainerID, tag); err != nil {
+		p.enforceRetention(client)
+
+	} else if commitLabels != nil {
+		// The image still needs to know about the dependencies it was built
+		// with, otherwise the next builds would never be skipped
+		fmt.Println("==> Container did not have any file changed, recording the build state on the image")
+		if err = p.commit(client, result.ContainerID, "latest", commitLabels); err != nil {
 			return result, err
 		}
 
@@ -311,3 +679,80 @@ func (p *project) buildWithCommand(client DockerClient, cliOpts *DockerRunOpts,
 
 	return result, nil
 }
+
+func printPorts(ports []PortMapping) {
+	fmt.Print(formatPorts(ports))
+}
+
+func formatPorts(ports []PortMapping) string {
+	if len(ports) == 0 {
+		return ""
+	}
+
+	out := "==> Published ports:\n"
+	for _, port := range ports {
+		out += fmt.Sprintf("    %s\n", port)
+	}
+	return out
+}
+
+// The directory on the container that matches the one devstep was run from
+func (p *project) workdir() string {
+	if p.CurrentDir == "" {
+		return p.GuestDir
+	}
+	return path.Join(p.GuestDir, filepath.ToSlash(p.CurrentDir))
+}
+
+// Merges the project defaults with the command specific options, the options
+// provided on the command line and the ones required by the command itself.
+// A working dir configured for the command takes precedence over the
+// project's guest dir.
+func (p *project) mergeOpts(commandOpts, cliOpts, requiredOpts *DockerRunOpts) *DockerRunOpts {
+	opts := p.Defaults.Merge(commandOpts, cliOpts, requiredOpts)
+	if commandOpts != nil && commandOpts.Workdir != "" {
+		opts.Workdir = commandOpts.Workdir
+	}
+	return opts
+}
+
+// Wraps the build command into a shell script that runs each provisioning
+// step right after it, stopping at the first one that fails so that the
+// container exits with a non zero status and does not get commited.
+func (p *project) withProvisioning(cmd []string) []string {
+	if len(p.Provision) == 0 {
+		return cmd
+	}
+
+	script := []string{shellJoin(cmd)}
+	for i, step := range p.Provision {
+		if len(step) == 0 {
+			continue
+		}
+		header := fmt.Sprintf("==> Provisioning step %d/%d: %s", i+1, len(p.Provision), strings.Join(step, " "))
+		script = append(script, "echo "+shellQuote(header), shellJoin(step))
+	}
+
+	return []string{"bash", "-c", strings.Join(script, " && ")}
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,40 @@
+package devstep
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Finds the root of the project that contains the provided directory, which
+// is the closest ancestor that has a devstep.yml file. If there is none, the
+// closest ancestor that is a git repository is used and if that can't be
+// found either, the directory itself is the project root. The devstep.yml
+// file from the home directory is not taken into account as it holds global
+// configs.
+func FindProjectRoot(dir, homeDirectory string) string {
+	if root := findAncestorWith(dir, "devstep.yml", homeDirectory); root != "" {
+		return root
+	}
+	if root := findAncestorWith(dir, ".git", ""); root != "" {
+		return root
+	}
+	return dir
+}
+
+func findAncestorWith(dir, name, ignoredDir string) string {
+	dir = filepath.Clean(dir)
+	ignoredDir = filepath.Clean(ignoredDir)
+	for {
+		if dir != ignoredDir {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,48 @@
+package devstep_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_FindProjectRootWithConfigFile(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-root-")
+	defer os.RemoveAll(tempDir)
+	tempDir, _ = filepath.EvalSymlinks(tempDir)
+
+	projectDir := tempDir + "/project"
+	os.MkdirAll(projectDir+"/.git", 0755)
+	os.MkdirAll(projectDir+"/app/src/models", 0755)
+	writeFile(projectDir+"/app/devstep.yml", "")
+
+	equals(t, projectDir+"/app", devstep.FindProjectRoot(projectDir+"/app/src/models", ""))
+	equals(t, projectDir+"/app", devstep.FindProjectRoot(projectDir+"/app", ""))
+}
+
+func Test_FindProjectRootFallsBackToGitRepository(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-root-")
+	defer os.RemoveAll(tempDir)
+	tempDir, _ = filepath.EvalSymlinks(tempDir)
+
+	// The devstep.yml from the home dir holds global configs
+	writeFile(tempDir+"/devstep.yml", "")
+	projectDir := tempDir + "/project"
+	os.MkdirAll(projectDir+"/.git", 0755)
+	os.MkdirAll(projectDir+"/src/models", 0755)
+
+	equals(t, projectDir, devstep.FindProjectRoot(projectDir+"/src/models", tempDir))
+}
+
+func Test_FindProjectRootDefaultsToTheDirItself(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-root-")
+	defer os.RemoveAll(tempDir)
+	tempDir, _ = filepath.EvalSymlinks(tempDir)
+
+	os.MkdirAll(tempDir+"/src", 0755)
+
+	equals(t, tempDir+"/src", devstep.FindProjectRoot(tempDir+"/src", ""))
+}
@@ -4,10 +4,13 @@ import (
 	"errors"
 	"github.com/fgrehm/devstep-cli/devstep"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func Test_Hack(t *testing.T) {
+	defer useTempSessionsDir()()
 	project, err := devstep.NewProject(&devstep.ProjectConfig{
 		BaseImage: "repo/name:tag",
 		HostDir:   "/path/on/host",
@@ -22,11 +25,11 @@ func Test_Hack(t *testing.T) {
 		runOpts = o
 		return &devstep.DockerRunResult{ContainerID: "cid", ExitCode: 0}, nil
 	}
-	clientMock.ListContainersFunc = func(string) ([]string, error) {
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
 		return []string{}, nil
 	}
-	clientMock.ContainerHasExecInstancesRunningFunc = func(string) bool {
-		return false
+	clientMock.ContainerHasExecInstancesRunningFunc = func(string) (bool, error) {
+		return false, nil
 	}
 
 	err = project.Hack(clientMock, &devstep.DockerRunOpts{
@@ -86,6 +89,8 @@ func Test_HackUsesDockerConfigs(t *testing.T) {
 	ok(t, err)
 
 	assert(t, *runOpts.Privileged, "Privileged is false")
+	equals(t, "true", runOpts.Labels[devstep.LabelAttached])
+	assert(t, runOpts.OnStart != nil, "Ports are not reported when the container starts")
 
 	assert(t, inArray("/path/on/host:/path/on/guest", runOpts.Volumes), "Project dir was not shared")
 	assert(t, inArray("/cache/path/on/host:/home/devstep/cache", runOpts.Volumes), "Cache dir was not shared")
@@ -99,6 +104,256 @@ func Test_HackUsesDockerConfigs(t *testing.T) {
 	equals(t, "VALUE", runOpts.Env["OTHER"])
 }
 
+func Test_HackUsesHackConfigsWhenStartingContainers(t *testing.T) {
+	defer useTempSessionsDir()()
+	var privileged *bool
+	{
+		t := true
+		privileged = &t
+	}
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CacheDir:    "/cache/path/on/host",
+		HackOpts: &devstep.DockerRunOpts{
+			Privileged: privileged,
+			Workdir:    "/path/on/guest/src",
+			Links:      []string{"other:link"},
+			Env:        map[string]string{"OTHER": "VALUE"},
+		},
+		ExecOpts: &devstep.DockerRunOpts{
+			Env: map[string]string{"EXEC": "VALUE"},
+		},
+	})
+	ok(t, err)
+
+	var runOpts *devstep.DockerRunOpts
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	var execOpts *devstep.DockerExecOpts
+	clientMock.ExecuteFunc = func(o *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		execOpts = o
+		return &devstep.DockerExecResult{ExitCode: 0}, nil
+	}
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		if runOpts == nil {
+			return []string{}, nil
+		}
+		return []string{"cid"}, nil
+	}
+	clientMock.ContainerHasExecInstancesRunningFunc = func(string) (bool, error) {
+		return true, nil
+	}
+
+	err = project.Hack(clientMock, nil)
+	ok(t, err)
+
+	assert(t, runOpts.Detach, "Container was not detached")
+	assert(t, *runOpts.Privileged, "Privileged is false")
+	equals(t, "/path/on/guest/src", runOpts.Workdir)
+	assert(t, inArray("other:link", runOpts.Links), "Hack links were not set")
+	equals(t, "VALUE", runOpts.Env["OTHER"])
+
+	equals(t, "cid", execOpts.ContainerID)
+	equals(t, []string{"/opt/devstep/bin/exec-entrypoint", "/opt/devstep/bin/hack"}, execOpts.Cmd)
+}
+
+func Test_Run(t *testing.T) {
+	var privileged *bool
+	{
+		t := true
+		privileged = &t
+	}
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		HostDir:   "/path/on/host",
+		GuestDir:  "/path/on/guest",
+		CacheDir:  "/cache/path/on/host",
+		Defaults: &devstep.DockerRunOpts{
+			Links: []string{"some:link"},
+			Env:   map[string]string{"SOME": "ENV"},
+		},
+		HackOpts: &devstep.DockerRunOpts{
+			Links: []string{"hack:link"},
+		},
+		RunOpts: &devstep.DockerRunOpts{
+			Privileged: privileged,
+			Links:      []string{"run:link"},
+			Env:        map[string]string{"RUN": "VALUE"},
+		},
+	})
+	ok(t, err)
+
+	var runOpts *devstep.DockerRunOpts
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ContainerID: "cid", ExitCode: 0}, nil
+	}
+
+	_, err = project.Run(clientMock, &devstep.DockerRunOpts{Cmd: []string{"--", "make"}})
+	ok(t, err)
+
+	equals(t, "repo/name:tag", runOpts.Image)
+	equals(t, []string{"--", "make"}, runOpts.Cmd)
+	assert(t, runOpts.AutoRemove, "AutoRemove is false")
+	assert(t, *runOpts.Privileged, "Privileged is false")
+	equals(t, []string{"some:link", "run:link"}, runOpts.Links)
+	equals(t, "ENV", runOpts.Env["SOME"])
+	equals(t, "VALUE", runOpts.Env["RUN"])
+}
+
+func Test_ExecReportsExitCode(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		NoTTY:     true,
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		return []string{"cid"}, nil
+	}
+	var execOpts *devstep.DockerExecOpts
+	clientMock.ExecuteFunc = func(o *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		execOpts = o
+		return &devstep.DockerExecResult{ExitCode: 3}, nil
+	}
+
+	result, err := project.Exec(clientMock, []string{"--", "false"})
+	ok(t, err)
+
+	equals(t, 3, result.ExitCode)
+	assert(t, execOpts.NoTTY, "Exec was run with a TTY")
+}
+
+func Test_ExecWithoutContainers(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{BaseImage: "repo/name:tag"})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		return []string{}, nil
+	}
+
+	_, err = project.Exec(clientMock, []string{"--", "make"})
+	assert(t, err != nil, "Exec without containers did not fail")
+}
+
+func Test_RunFromProjectSubdirectory(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:  "repo/name:tag",
+		HostDir:    "/path/on/host",
+		GuestDir:   "/path/on/guest",
+		CurrentDir: "src/models",
+		CacheDir:   "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	var runOpts *devstep.DockerRunOpts
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+
+	_, err = project.Run(clientMock, &devstep.DockerRunOpts{Cmd: []string{"--", "make"}})
+	ok(t, err)
+
+	equals(t, "/path/on/guest/src/models", runOpts.Workdir)
+	assert(t, inArray("/path/on/host:/path/on/guest", runOpts.Volumes), "Project root was not mounted")
+}
+
+func Test_ExecFromProjectSubdirectory(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:  "repo/name:tag",
+		HostDir:    "/path/on/host",
+		GuestDir:   "/path/on/guest",
+		CurrentDir: "src/models",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		return []string{"cid"}, nil
+	}
+	var execOpts *devstep.DockerExecOpts
+	clientMock.ExecuteFunc = func(o *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		execOpts = o
+		return &devstep.DockerExecResult{}, nil
+	}
+
+	_, err = project.Exec(clientMock, []string{"--", "make"})
+	ok(t, err)
+
+	equals(t, []string{
+		"bash", "-c", `cd '/path/on/guest/src/models' && exec "$@"`, "bash",
+		"/opt/devstep/bin/exec-entrypoint", "--", "make",
+	}, execOpts.Cmd)
+}
+
+func Test_RunAndBuildWithoutTTY(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		HostDir:   "/path/on/host",
+		GuestDir:  "/path/on/guest",
+		CacheDir:  "/cache/path/on/host",
+		NoTTY:     true,
+	})
+	ok(t, err)
+
+	var runOpts *devstep.DockerRunOpts
+	exitCode := 4
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ContainerID: "cid", ExitCode: exitCode}, nil
+	}
+
+	result, err := project.Run(clientMock, &devstep.DockerRunOpts{Cmd: []string{"--", "make"}})
+	ok(t, err)
+	equals(t, 4, result.ExitCode)
+	assert(t, !runOpts.Pty, "Pty was enabled for run")
+	assert(t, !runOpts.Detach, "Container was detached")
+
+	exitCode = 0
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	assert(t, !runOpts.Pty, "Pty was enabled for build")
+}
+
+func Test_ExecUsesExecConfigs(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		ExecOpts: &devstep.DockerRunOpts{
+			Env: map[string]string{"FOO": "bar", "BAR": "baz"},
+		},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		return []string{"cid"}, nil
+	}
+	var execOpts *devstep.DockerExecOpts
+	clientMock.ExecuteFunc = func(o *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		execOpts = o
+		return &devstep.DockerExecResult{ExitCode: 0}, nil
+	}
+
+	_, err = project.Exec(clientMock, []string{"--", "make"})
+	ok(t, err)
+
+	equals(t, "cid", execOpts.ContainerID)
+	equals(t, []string{"env", "BAR=baz", "FOO=bar", "/opt/devstep/bin/exec-entrypoint", "--", "make"}, execOpts.Cmd)
+}
+
 func Test_Build(t *testing.T) {
 	project, err := devstep.NewProject(&devstep.ProjectConfig{
 		BaseImage:      "repo/name:tag",
@@ -223,6 +478,61 @@ func Test_BuildUsesGlobalDockerConfigs(t *testing.T) {
 	equals(t, "", runOpts.Env["OTHER"])
 }
 
+func Test_BuildUsesBuildConfigs(t *testing.T) {
+	var privilegedTrue *bool
+	{
+		t := true
+		privilegedTrue = &t
+	}
+	var privilegedFalse *bool
+	{
+		f := false
+		privilegedFalse = &f
+	}
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		HostDir:  "/path/on/host",
+		GuestDir: "/path/on/guest",
+		CacheDir: "/cache/path/on/host",
+		Defaults: &devstep.DockerRunOpts{
+			Privileged: privilegedFalse,
+			Links:      []string{"some:link"},
+		},
+		BuildOpts: &devstep.DockerRunOpts{
+			Privileged: privilegedTrue,
+			Links:      []string{"build:link"},
+			Volumes:    []string{"/build:/volume"},
+			Env:        map[string]string{"BUILD": "VALUE"},
+		},
+		BootstrapOpts: &devstep.DockerRunOpts{
+			Links: []string{"bootstrap:link"},
+		},
+	})
+	ok(t, err)
+
+	var runOpts *devstep.DockerRunOpts
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ExitCode: 0, ContainerID: "cid"}, nil
+	}
+
+	err = project.Build(clientMock, &devstep.DockerRunOpts{})
+	ok(t, err)
+
+	assert(t, *runOpts.Privileged, "Privileged is set to false")
+	equals(t, []string{"some:link", "build:link"}, runOpts.Links)
+	assert(t, inArray("/build:/volume", runOpts.Volumes), "Build volumes were not set")
+	equals(t, "VALUE", runOpts.Env["BUILD"])
+
+	err = project.Bootstrap(clientMock, &devstep.DockerRunOpts{})
+	ok(t, err)
+
+	assert(t, !*runOpts.Privileged, "Privileged is set to true")
+	equals(t, []string{"some:link", "bootstrap:link"}, runOpts.Links)
+	equals(t, []string{"bash"}, runOpts.Cmd)
+}
+
 func Test_BuildWithErrorOnRun(t *testing.T) {
 	project, err := devstep.NewProject(&devstep.ProjectConfig{
 		BaseImage: "repo/name:tag",
@@ -244,6 +554,194 @@ func Test_BuildWithErrorOnRun(t *testing.T) {
 	equals(t, runError, err)
 }
 
+func Test_Commit(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:tag",
+		RepositoryName: "repo/name",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.LookupContainerIDFunc = func(name string) (string, error) {
+		return "cid-" + name, nil
+	}
+	commits := []*devstep.DockerCommitOpts{}
+	clientMock.CommitFunc = func(o *devstep.DockerCommitOpts) error {
+		commits = append(commits, o)
+		return nil
+	}
+
+	err = project.Commit(clientMock, "container", nil)
+	ok(t, err)
+
+	equals(t, 2, len(commits))
+	equals(t, "latest", commits[0].Tag)
+	equals(t, "cid-container", commits[0].ContainerID)
+	equals(t, "repo/name", commits[0].RepositoryName)
+	assert(t, commits[1].Tag != "latest", "Expected a timestamp tag")
+}
+
+func Test_CommitNamedSnapshot(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:tag",
+		RepositoryName: "repo/name",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.LookupContainerIDFunc = func(name string) (string, error) {
+		return "cid", nil
+	}
+	commits := []*devstep.DockerCommitOpts{}
+	clientMock.CommitFunc = func(o *devstep.DockerCommitOpts) error {
+		commits = append(commits, o)
+		return nil
+	}
+
+	pause := true
+	err = project.Commit(clientMock, "container", &devstep.CommitOpts{
+		Tag:     "before-upgrade",
+		Message: "Before upgrading",
+		Author:  "Someone",
+		Pause:   &pause,
+	})
+	ok(t, err)
+
+	equals(t, 1, len(commits))
+	equals(t, "repo/name", commits[0].Labels[devstep.LabelRepository])
+	commits[0].Labels = nil
+	equals(t, []*devstep.DockerCommitOpts{
+		{ContainerID: "cid", RepositoryName: "repo/name", Tag: "before-upgrade", Message: "Before upgrading", Author: "Someone", Pause: &pause},
+	}, commits)
+
+	err = project.Commit(clientMock, "container", &devstep.CommitOpts{Tag: "invalid tag"})
+	equals(t, errors.New("Invalid tag 'invalid tag'"), err)
+	equals(t, 1, len(commits))
+}
+
+func Test_ContainersAreLabeled(t *testing.T) {
+	defer useTempSessionsDir()()
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage:    "source/image:tag",
+		BaseImage:      "repo/name:tag",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+		GuestDir:       "/path/on/guest",
+		CacheDir:       "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	labels := []map[string]string{}
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		labels = append(labels, o.Labels)
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	var commitLabels map[string]string
+	clientMock.CommitFunc = func(o *devstep.DockerCommitOpts) error {
+		commitLabels = o.Labels
+		return nil
+	}
+	var listFilters map[string]string
+	clientMock.ListContainersFunc = func(filters map[string]string) ([]string, error) {
+		listFilters = filters
+		if len(labels) == 0 {
+			return []string{}, nil
+		}
+		return []string{"cid"}, nil
+	}
+	clientMock.ExecuteFunc = func(*devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		return &devstep.DockerExecResult{}, nil
+	}
+	clientMock.ContainerHasExecInstancesRunningFunc = func(string) (bool, error) {
+		return false, nil
+	}
+
+	ok(t, project.Hack(clientMock, &devstep.DockerRunOpts{}))
+	_, err = project.Run(clientMock, &devstep.DockerRunOpts{Cmd: []string{"make"}})
+	ok(t, err)
+	ok(t, project.Build(clientMock, &devstep.DockerRunOpts{}))
+	ok(t, project.Bootstrap(clientMock, &devstep.DockerRunOpts{}))
+
+	roles := []string{}
+	for _, l := range labels {
+		equals(t, "/path/on/host", l[devstep.LabelProject])
+		equals(t, "repo/name", l[devstep.LabelRepository])
+		equals(t, devstep.Version, l[devstep.LabelVersion])
+		equals(t, project.Config().Hash(), l[devstep.LabelConfigHash])
+		roles = append(roles, l[devstep.LabelRole])
+	}
+	equals(t, []string{"hack", "run", "build", "bootstrap"}, roles)
+	equals(t, map[string]string{devstep.LabelProject: "/path/on/host", devstep.LabelRole: "hack"}, listFilters)
+	equals(t, "/path/on/host", commitLabels[devstep.LabelProject])
+	equals(t, project.Config().Hash(), commitLabels[devstep.LabelConfigHash])
+	equals(t, "", commitLabels[devstep.LabelRole])
+	equals(t, "", commitLabels[devstep.LabelKeep])
+}
+
+func Test_Status(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	now := time.Now()
+	clientMock := NewMockClient()
+	var filters map[string]string
+	clientMock.FindContainersFunc = func(labels map[string]string) ([]*devstep.DockerContainer, error) {
+		filters = labels
+		return []*devstep.DockerContainer{
+			{ID: "old", Created: now.Add(-time.Hour)},
+			{ID: "new", Created: now},
+		}, nil
+	}
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return []string{"latest", "20150101000000"}, nil
+	}
+
+	status, err := project.Status(clientMock)
+	ok(t, err)
+
+	equals(t, map[string]string{devstep.LabelProject: "/path/on/host"}, filters)
+	equals(t, 2, len(status.Containers))
+	equals(t, "new", status.Containers[0].ID)
+	equals(t, 1, len(status.Snapshots))
+	equals(t, "20150101000000", status.Snapshots[0].Tag)
+}
+
+func Test_BuildInterrupted(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		HostDir:   "/path/on/host",
+		GuestDir:  "/path/on/guest",
+		CacheDir:  "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ContainerID: "cid", ExitCode: 0}, &devstep.InterruptedError{Signal: syscall.SIGTERM}
+	}
+	commits := 0
+	clientMock.CommitFunc = func(o *devstep.DockerCommitOpts) error {
+		commits++
+		return nil
+	}
+	removedID := ""
+	clientMock.RemoveContainerFunc = func(id string) error {
+		removedID = id
+		return nil
+	}
+
+	err = project.Build(clientMock, &devstep.DockerRunOpts{})
+	_, interrupted := err.(*devstep.InterruptedError)
+	assert(t, interrupted, "Expected an InterruptedError")
+	equals(t, 0, commits)
+	equals(t, "cid", removedID)
+}
+
 func Test_BuildWithErrorOnCommit(t *testing.T) {
 	project, err := devstep.NewProject(&devstep.ProjectConfig{
 		BaseImage: "repo/name:tag",
@@ -283,6 +781,99 @@ func Test_BuildWithBadExitCode(t *testing.T) {
 	assert(t, err != nil, "Did not error")
 }
 
+func Test_BuildWithProvisioning(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:tag",
+		HostDir:        "/path/on/host",
+		GuestDir:       "/path/on/guest",
+		CacheDir:       "/cache/path/on/host",
+		RepositoryName: "repo-name",
+		Provision: [][]string{
+			[]string{"configure-addons", "redis"},
+			[]string{"echo", "it's done"},
+		},
+	})
+	ok(t, err)
+
+	var runOpts *devstep.DockerRunOpts
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ExitCode: 0, ContainerID: "cid"}, nil
+	}
+	commits := 0
+	clientMock.CommitFunc = func(o *devstep.DockerCommitOpts) error {
+		commits++
+		return nil
+	}
+
+	err = project.Build(clientMock, &devstep.DockerRunOpts{})
+	ok(t, err)
+
+	equals(t, []string{"bash", "-c", strings.Join([]string{
+		"'/opt/devstep/bin/build-project' '/path/on/guest'",
+		"echo '==> Provisioning step 1/2: configure-addons redis'",
+		"'configure-addons' 'redis'",
+		`echo '==> Provisioning step 2/2: echo it'\''s done'`,
+		`'echo' 'it'\''s done'`,
+	}, " && ")}, runOpts.Cmd)
+	equals(t, 2, commits)
+}
+
+func Test_BuildWithFailingProvisioningStep(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		HostDir:   "/path/on/host",
+		GuestDir:  "/path/on/guest",
+		CacheDir:  "/cache/path/on/host",
+		Provision: [][]string{[]string{"false"}},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ExitCode: 1, ContainerID: "cid"}, nil
+	}
+	commited := false
+	clientMock.CommitFunc = func(o *devstep.DockerCommitOpts) error {
+		commited = true
+		return nil
+	}
+	var removeId string
+	clientMock.RemoveContainerFunc = func(r string) error {
+		removeId = r
+		return nil
+	}
+
+	err = project.Build(clientMock, &devstep.DockerRunOpts{})
+	assert(t, err != nil, "Did not error")
+	assert(t, !commited, "Container was commited")
+	equals(t, "cid", removeId)
+}
+
+func Test_BootstrapDoesNotProvision(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		HostDir:   "/path/on/host",
+		GuestDir:  "/path/on/guest",
+		CacheDir:  "/cache/path/on/host",
+		Provision: [][]string{[]string{"configure-addons", "redis"}},
+	})
+	ok(t, err)
+
+	var runOpts *devstep.DockerRunOpts
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ExitCode: 0, ContainerID: "cid"}, nil
+	}
+
+	err = project.Bootstrap(clientMock, &devstep.DockerRunOpts{})
+	ok(t, err)
+
+	equals(t, []string{"bash"}, runOpts.Cmd)
+}
+
 func Test_Clean(t *testing.T) {
 	project, err := devstep.NewProject(&devstep.ProjectConfig{
 		RepositoryName: "my/project",
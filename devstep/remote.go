@@ -0,0 +1,121 @@
+package devstep
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The repository the project images are pushed to and pulled from. Images
+// only go to the Docker Hub when the repository was set explicitly, as the
+// default one could publish the environment under someone else's namespace.
+func (p *project) remoteRepository() (string, error) {
+	if p.Registry != "" {
+		return p.Registry + "/" + p.RepositoryName, nil
+	}
+	if p.Source("repository").Type == ConfigSourceDefault {
+		return "", errors.New("No registry configured, set 'registry' on devstep.yml (or 'repository' to use the Docker Hub)")
+	}
+	return p.RepositoryName, nil
+}
+
+// Pushes `latest` and the timestamped images of the project, oldest first
+func (p *project) Push(client DockerClient) error {
+	remote, err := p.remoteRepository()
+	if err != nil {
+		return err
+	}
+
+	tags, err := client.ListTags(p.RepositoryName)
+	if err != nil {
+		return err
+	}
+	if !containsString(tags, "latest") {
+		return errors.New("Image '" + p.RepositoryName + ":latest' does not exist, run 'devstep build' first")
+	}
+
+	toPush := []string{}
+	for _, tag := range tags {
+		if _, err := time.Parse(snapshotTagFormat, tag); err == nil {
+			toPush = append(toPush, tag)
+		}
+	}
+	sort.Strings(toPush)
+	toPush = append(toPush, "latest")
+
+	for _, tag := range toPush {
+		fmt.Printf("==> Pushing '%s:%s'\n", remote, tag)
+		if err = p.pushTag(client, remote, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Images are tagged with the registry name while being pushed
+func (p *project) pushTag(client DockerClient, remote, tag string) error {
+	if remote == p.RepositoryName {
+		return client.PushImage(remote, tag)
+	}
+
+	if err := client.TagImage(p.RepositoryName+":"+tag, remote, tag); err != nil {
+		return errors.New("Error tagging image:\n  " + err.Error())
+	}
+	err := client.PushImage(remote, tag)
+	if removeErr := client.RemoveImage(remote + ":" + tag); removeErr != nil {
+		log.Debug("Error removing tag '%s:%s': %s", remote, tag, removeErr)
+	}
+	return err
+}
+
+// Pulls the `latest` image pushed by someone else and makes it the project
+// environment
+func (p *project) Pull(client DockerClient) error {
+	remote, err := p.remoteRepository()
+	if err != nil {
+		return err
+	}
+
+	previousID, err := p.latestImageID(client)
+	if err != nil {
+		return err
+	}
+
+	existing, err := client.InspectImage(remote + ":latest")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("==> Pulling '%s:latest'\n", remote)
+	if err = client.PullImage(remote, "latest"); err != nil {
+		return err
+	}
+
+	if err = p.adoptImage(client, remote+":latest", previousID, existing != nil); err != nil {
+		return err
+	}
+	p.BaseImage = p.RepositoryName + ":latest"
+	return nil
+}
+
+// Projects that don't have an image yet start from the one on the registry
+// when there is one, falling back to the source image
+func (p *project) pullFirstEnvironment(client DockerClient) {
+	if p.Registry == "" || p.BaseImage != p.SourceImage {
+		return
+	}
+	if err := p.Pull(client); err != nil {
+		fmt.Printf("==> Unable to pull the environment from '%s', starting from '%s'\n", p.Registry, p.SourceImage)
+		log.Debug("Error pulling environment: %s", err)
+	}
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package devstep_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_PushAndPull(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+	defer useDockerConfig(`{}`)()
+
+	latest := server.AddImage("devstep/project:latest", "devstep/project:20160102000000")
+	server.AddImage("devstep/project:20160101000000")
+	server.AddImage("devstep/project:named")
+	pusher, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project", Registry: "localhost:5000"})
+
+	ok(t, pusher.Push(client))
+
+	equals(t, latest.ID, server.RegistryImage("localhost:5000/devstep/project:latest").ID)
+	equals(t, latest.ID, server.RegistryImage("localhost:5000/devstep/project:20160102000000").ID)
+	assert(t, server.RegistryImage("localhost:5000/devstep/project:20160101000000") != nil, "Timestamped image was not pushed")
+	assert(t, server.RegistryImage("localhost:5000/devstep/project:named") == nil, "Named snapshot was pushed")
+	assert(t, server.Image("localhost:5000/devstep/project:latest") == nil, "Registry tag was kept")
+
+	otherServer, otherClient := newTestClient()
+	defer otherServer.Close()
+	// Each fake daemon has its own registry, so the pushed image is copied over
+	otherServer.AddRegistryImage("localhost:5000/devstep/project:latest").ID = latest.ID
+	puller, _ := devstep.NewProject(&devstep.ProjectConfig{
+		RepositoryName: "devstep/project",
+		Registry:       "localhost:5000",
+		SourceImage:    "source/image",
+		BaseImage:      "source/image",
+	})
+
+	ok(t, puller.Pull(otherClient))
+
+	equals(t, latest.ID, otherServer.Image("devstep/project:latest").ID)
+	equals(t, 2, len(otherServer.Image("devstep/project:latest").RepoTags))
+	assert(t, otherServer.Image("localhost:5000/devstep/project:latest") == nil, "Registry tag was kept")
+	equals(t, "devstep/project:latest", puller.Config().BaseImage)
+}
+
+func Test_PushWithoutImage(t *testing.T) {
+	project, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project", Registry: "localhost:5000"})
+	clientMock := NewMockClient()
+
+	err := project.Push(clientMock)
+	equals(t, "Image 'devstep/project:latest' does not exist, run 'devstep build' first", errString(err))
+}
+
+func Test_PushAndPullRequireARegistry(t *testing.T) {
+	project, _ := devstep.NewProject(&devstep.ProjectConfig{RepositoryName: "devstep/project"})
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return []string{"latest"}, nil
+	}
+	clientMock.PushImageFunc = func(name, tag string) error {
+		t.Fatal("Image was pushed")
+		return nil
+	}
+	clientMock.PullImageFunc = func(name, tag string) error {
+		t.Fatal("Image was pulled")
+		return nil
+	}
+
+	expected := "No registry configured, set 'registry' on devstep.yml (or 'repository' to use the Docker Hub)"
+	equals(t, expected, errString(project.Push(clientMock)))
+	equals(t, expected, errString(project.Pull(clientMock)))
+}
+
+func Test_PushToDockerHub(t *testing.T) {
+	config := &devstep.ProjectConfig{RepositoryName: "someone/project"}
+	config.SetSource("repository", &devstep.ConfigSource{Type: devstep.ConfigSourceFile, File: "devstep.yml"})
+	project, _ := devstep.NewProject(config)
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return []string{"latest", "20160102000000", "20160101000000"}, nil
+	}
+	pushed := []string{}
+	clientMock.PushImageFunc = func(name, tag string) error {
+		pushed = append(pushed, name+":"+tag)
+		return nil
+	}
+	clientMock.TagImageFunc = func(string, string, string) error {
+		t.Fatal("Image was tagged")
+		return nil
+	}
+
+	ok(t, project.Push(clientMock))
+	equals(t, []string{"someone/project:20160101000000", "someone/project:20160102000000", "someone/project:latest"}, pushed)
+}
+
+func Test_HackPullsEnvironmentBeforeFirstSession(t *testing.T) {
+	project, _ := devstep.NewProject(&devstep.ProjectConfig{
+		RepositoryName: "devstep/project",
+		Registry:       "localhost:5000",
+		SourceImage:    "source/image",
+		BaseImage:      "source/image",
+	})
+	clientMock := NewMockClient()
+	pulled := []string{}
+	clientMock.PullImageFunc = func(name, tag string) error {
+		pulled = append(pulled, name+":"+tag)
+		return nil
+	}
+	clientMock.InspectImageFunc = func(name string) (*devstep.DockerImage, error) {
+		if name == "devstep/project:latest" && len(pulled) == 0 {
+			return nil, nil
+		}
+		return &devstep.DockerImage{ID: "pulled-id"}, nil
+	}
+	var runImage string
+	clientMock.RunFunc = func(opts *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runImage = opts.Image
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		return []string{}, nil
+	}
+
+	defer useTempSessionsDir()()
+	ok(t, project.Hack(clientMock, &devstep.DockerRunOpts{}))
+
+	equals(t, []string{"localhost:5000/devstep/project:latest"}, pulled)
+	equals(t, "devstep/project:latest", runImage)
+}
+
+func Test_LoadsRegistry(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	defer os.RemoveAll(tempDir)
+
+	writeFile(tempDir+"/devstep.yml", "registry: 'registry.example.com:5000'\n")
+	loader, _ := newConfigLoader("", tempDir)
+	config, err := loader.Load()
+	ok(t, err)
+	equals(t, "registry.example.com:5000", config.Registry)
+
+	writeFile(tempDir+"/devstep.yml", "registry: 'https://registry.example.com'\n")
+	_, err = loader.Load()
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, "Invalid registry 'https://registry.example.com', expected 'host[:port]'", errs[0].Message)
+}
@@ -0,0 +1,93 @@
+package devstep
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The format of the tags that get commited along with `latest` on each build
+const snapshotTagFormat = "20060102150405"
+
+// How many of the timestamped images built for a project are kept around.
+// Images are kept if they match any of the rules and policies without rules
+// keep everything.
+type RetentionPolicy struct {
+	KeepLast      int           // number of most recent images to keep
+	KeepNewerThan time.Duration // images built within this period are kept
+}
+
+// Policies without rules (or no policy at all) keep every image
+func (r *RetentionPolicy) Empty() bool {
+	return r == nil || (r.KeepLast <= 0 && r.KeepNewerThan <= 0)
+}
+
+func (r *RetentionPolicy) String() string {
+	rules := []string{}
+	if r.KeepLast > 0 {
+		rules = append(rules, fmt.Sprintf("keep last %d", r.KeepLast))
+	}
+	if r.KeepNewerThan > 0 {
+		rules = append(rules, "keep newer than "+r.KeepNewerThan.String())
+	}
+	return strings.Join(rules, ", ")
+}
+
+var retentionAgePattern = regexp.MustCompile(`^(\d+)d$`)
+
+// Parses the max age of images, which can be provided as a Go duration
+// (like `36h`) or as a number of days (like `30d`)
+func ParseRetentionAge(age string) (time.Duration, error) {
+	if match := retentionAgePattern.FindStringSubmatch(age); match != nil {
+		days, _ := strconv.Atoi(match[1])
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(age)
+	if err != nil || duration < 0 {
+		return 0, errors.New("Invalid age '" + age + "', expected a duration like '72h' or '30d'")
+	}
+	return duration, nil
+}
+
+// Returns the timestamped tags that are not kept by the policy, newest first
+func (r *RetentionPolicy) prunableTags(tags []string, now time.Time) []string {
+	if r.Empty() {
+		return []string{}
+	}
+
+	snapshots := snapshotTags{}
+	for _, tag := range tags {
+		if created, err := time.ParseInLocation(snapshotTagFormat, tag, time.Local); err == nil {
+			snapshots = append(snapshots, snapshotTag{tag, created})
+		}
+	}
+	sort.Sort(snapshots)
+
+	prunable := []string{}
+	for i, snapshot := range snapshots {
+		if r.KeepLast > 0 && i < r.KeepLast {
+			continue
+		}
+		if r.KeepNewerThan > 0 && now.Sub(snapshot.created) < r.KeepNewerThan {
+			continue
+		}
+		prunable = append(prunable, snapshot.tag)
+	}
+	return prunable
+}
+
+type snapshotTag struct {
+	tag     string
+	created time.Time
+}
+
+// Sorts tags from the newest to the oldest
+type snapshotTags []snapshotTag
+
+func (s snapshotTags) Len() int           { return len(s) }
+func (s snapshotTags) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s snapshotTags) Less(i, j int) bool { return s[i].created.After(s[j].created) }
@@ -0,0 +1,243 @@
+package devstep_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_ParseRetentionAge(t *testing.T) {
+	examples := map[string]time.Duration{
+		"36h":  36 * time.Hour,
+		"90m":  90 * time.Minute,
+		"30d":  30 * 24 * time.Hour,
+		"0d":   0,
+		"1h5m": time.Hour + 5*time.Minute,
+	}
+	for age, expected := range examples {
+		duration, err := devstep.ParseRetentionAge(age)
+		ok(t, err)
+		equals(t, expected, duration)
+	}
+
+	for _, age := range []string{"", "30", "a week", "-1h", "1.5d"} {
+		_, err := devstep.ParseRetentionAge(age)
+		assert(t, err != nil, "Expected '"+age+"' to be invalid")
+	}
+}
+
+func Test_PruneKeepsLastImages(t *testing.T) {
+	now := time.Now()
+	tags := []string{"latest", "custom"}
+	for i := 0; i < 5; i++ {
+		tags = append(tags, snapshotTag(now.Add(-time.Duration(i)*time.Hour)))
+	}
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		Retention:      &devstep.RetentionPolicy{KeepLast: 2},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return tags, nil
+	}
+	removed := []string{}
+	clientMock.RemoveImageFunc = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+
+	pruned, err := project.Prune(clientMock, false)
+	ok(t, err)
+
+	expected := []string{"repo/name:" + tags[4], "repo/name:" + tags[5], "repo/name:" + tags[6]}
+	equals(t, expected, pruned)
+	equals(t, expected, removed)
+}
+
+func Test_PruneKeepsNewerImages(t *testing.T) {
+	now := time.Now()
+	recent := snapshotTag(now.Add(-time.Hour))
+	old := snapshotTag(now.Add(-72 * time.Hour))
+	older := snapshotTag(now.Add(-96 * time.Hour))
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		Retention:      &devstep.RetentionPolicy{KeepNewerThan: 48 * time.Hour},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return []string{"latest", old, recent, older}, nil
+	}
+
+	pruned, err := project.Prune(clientMock, true)
+	ok(t, err)
+	equals(t, []string{"repo/name:" + old, "repo/name:" + older}, pruned)
+
+	project.Config().Retention = &devstep.RetentionPolicy{KeepLast: 2, KeepNewerThan: 48 * time.Hour}
+
+	pruned, err = project.Prune(clientMock, true)
+	ok(t, err)
+	equals(t, []string{"repo/name:" + older}, pruned)
+}
+
+func Test_PruneNeverRemovesTheLatestImage(t *testing.T) {
+	now := time.Now()
+	current := snapshotTag(now.Add(-72 * time.Hour))
+	newer := snapshotTag(now.Add(-time.Hour))
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		Retention:      &devstep.RetentionPolicy{KeepLast: 1},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return []string{"latest", current, newer}, nil
+	}
+	clientMock.InspectImageFunc = func(name string) (*devstep.DockerImage, error) {
+		if name == "repo/name:latest" || name == "repo/name:"+current {
+			return &devstep.DockerImage{ID: "rolled-back"}, nil
+		}
+		return &devstep.DockerImage{ID: name}, nil
+	}
+
+	pruned, err := project.Prune(clientMock, false)
+	ok(t, err)
+	equals(t, []string{}, pruned)
+}
+
+func Test_PruneDryRunDoesNotRemoveImages(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		Retention:      &devstep.RetentionPolicy{KeepLast: 1},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return []string{"latest", "20150101000000", "20150102000000"}, nil
+	}
+	clientMock.RemoveImageFunc = func(name string) error {
+		t.Fatalf("Removed image '%s' on a dry run", name)
+		return nil
+	}
+
+	pruned, err := project.Prune(clientMock, true)
+	ok(t, err)
+	equals(t, []string{"repo/name:20150101000000"}, pruned)
+}
+
+func Test_PruneWithoutRetentionPolicy(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		t.Fatal("Tags were listed without a retention policy")
+		return nil, nil
+	}
+
+	pruned, err := project.Prune(clientMock, false)
+	ok(t, err)
+	equals(t, []string{}, pruned)
+}
+
+func Test_BuildEnforcesRetentionPolicy(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		Retention:      &devstep.RetentionPolicy{KeepLast: 1},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	commited := []string{}
+	clientMock.CommitFunc = func(opts *devstep.DockerCommitOpts) error {
+		commited = append(commited, opts.Tag)
+		return nil
+	}
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return append([]string{"20150101000000"}, commited...), nil
+	}
+	removed := []string{}
+	clientMock.RemoveImageFunc = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+
+	err = project.Build(clientMock, &devstep.DockerRunOpts{})
+	ok(t, err)
+
+	equals(t, 2, len(commited))
+	equals(t, []string{"repo/name:20150101000000"}, removed)
+}
+
+func Test_LoadsRetentionPolicy(t *testing.T) {
+	tempHomeDir, _ := ioutil.TempDir("", "devstep-home-")
+	writeFile(tempHomeDir+"/devstep.yml", `
+retention:
+  keep_last: 10
+  keep_newer_than: '30d'
+`)
+	defer os.RemoveAll(tempHomeDir)
+	tempProjectDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempProjectDir+"/devstep.yml", `
+retention:
+  keep_last: 3
+`)
+	defer os.RemoveAll(tempProjectDir)
+
+	loader, _ := newConfigLoader(tempHomeDir, tempProjectDir)
+	config, err := loader.Load()
+	ok(t, err)
+
+	equals(t, &devstep.RetentionPolicy{KeepLast: 3, KeepNewerThan: 30 * 24 * time.Hour}, config.Retention)
+	equals(t, tempProjectDir+"/devstep.yml:3", config.Source("retention.keep_last").String())
+	equals(t, tempHomeDir+"/devstep.yml:4", config.Source("retention.keep_newer_than").String())
+}
+
+func Test_ReportsInvalidRetentionPolicies(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "devstep-project-")
+	writeFile(tempDir+"/devstep.yml", `
+retention:
+  keep_last: 'all'
+  keep_newer_than: 'a week'
+hack:
+  retention:
+    keep_last: 1
+`)
+	defer os.RemoveAll(tempDir)
+
+	loader, _ := newConfigLoader("", tempDir)
+	_, err := loader.Load()
+
+	errs, isConfigErrors := err.(devstep.ConfigErrors)
+	assert(t, isConfigErrors, "Expected ConfigErrors, got "+errString(err))
+	equals(t, 3, len(errs))
+	equals(t, 3, errs[0].Line)
+	equals(t, "Expected 'keep_last' to be a positive number", errs[0].Message)
+	equals(t, 4, errs[1].Line)
+	equals(t, "Invalid age 'a week', expected a duration like '72h' or '30d'", errs[1].Message)
+	equals(t, 6, errs[2].Line)
+	equals(t, "Unknown key 'retention'", errs[2].Message)
+}
+
+func snapshotTag(t time.Time) string {
+	return t.Local().Format("20060102150405")
+}
@@ -0,0 +1,111 @@
+package devstep
+
+import (
+	"fmt"
+)
+
+// A container the project depends on (like a database), started before the
+// project containers and linked to them using its alias
+type ServiceConfig struct {
+	Alias   string            // the service name on devstep.yml, also used as the link alias
+	Name    string            // name of the service container
+	Image   string            // image used for creating the service container
+	Env     map[string]string // environment variables
+	Volumes []string          // volumes shared with the service container
+	Publish []string          // ports published to the host (hostPort:containerPort)
+}
+
+// The state of the container associated with a service
+type ServiceStatus struct {
+	*ServiceConfig
+	ContainerID string // blank if the container has not been created yet
+	Running     bool
+}
+
+// Start the containers for the configured services, reusing the ones that
+// have already been created
+func (p *project) StartServices(client DockerClient) error {
+	_, err := p.startServices(client)
+	return err
+}
+
+// Stop the containers for the configured services, optionally removing them
+func (p *project) StopServices(client DockerClient, remove bool) error {
+	for _, service := range p.Services {
+		status, err := client.ContainerStatus(service.Name)
+		if err != nil {
+			return err
+		}
+		if status == nil {
+			continue
+		}
+
+		if status.Running {
+			fmt.Printf("==> Stopping '%s' service\n", service.Alias)
+			if err = client.StopContainer(status.ContainerID); err != nil {
+				return err
+			}
+		}
+		if remove {
+			fmt.Printf("==> Removing '%s' service container\n", service.Alias)
+			if err = client.RemoveContainer(status.ContainerID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *project) ServicesStatus(client DockerClient) ([]*ServiceStatus, error) {
+	statuses := []*ServiceStatus{}
+	for _, service := range p.Services {
+		containerStatus, err := client.ContainerStatus(service.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		status := &ServiceStatus{ServiceConfig: service}
+		if containerStatus != nil {
+			status.ContainerID = containerStatus.ContainerID
+			status.Running = containerStatus.Running
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Makes sure all services are running and returns the links that should be
+// used by the project containers
+func (p *project) startServices(client DockerClient) ([]string, error) {
+	links := []string{}
+	for _, service := range p.Services {
+		status, err := client.ContainerStatus(service.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if status == nil {
+			fmt.Printf("==> Creating '%s' service container using '%s'\n", service.Alias, service.Image)
+			_, err = client.Run(&DockerRunOpts{
+				Name:    service.Name,
+				Image:   service.Image,
+				Detach:  true,
+				Env:     service.Env,
+				Volumes: service.Volumes,
+				Publish: service.Publish,
+				Labels:  p.labels(RoleService),
+			})
+		} else if !status.Running {
+			fmt.Printf("==> Starting '%s' service\n", service.Alias)
+			err = client.StartContainer(status.ContainerID)
+		} else {
+			log.Debug("Service '%s' is already running (ID='%s')", service.Alias, status.ContainerID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, service.Name+":"+service.Alias)
+	}
+	return links, nil
+}
@@ -0,0 +1,164 @@
+package devstep_test
+
+import (
+	"errors"
+	"github.com/fgrehm/devstep-cli/devstep"
+	"testing"
+)
+
+func Test_BuildStartsAndLinksServices(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		HostDir:   "/path/on/host",
+		GuestDir:  "/path/on/guest",
+		CacheDir:  "/cache/path/on/host",
+		Services: []*devstep.ServiceConfig{
+			&devstep.ServiceConfig{
+				Alias:   "db",
+				Name:    "project-db",
+				Image:   "postgres:9.4",
+				Env:     map[string]string{"POSTGRES_PASSWORD": "secret"},
+				Publish: []string{"5432:5432"},
+			},
+			&devstep.ServiceConfig{Alias: "mc", Name: "project-mc", Image: "memcached"},
+			&devstep.ServiceConfig{Alias: "redis", Name: "project-redis", Image: "redis"},
+		},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ContainerStatusFunc = func(name string) (*devstep.DockerContainerStatus, error) {
+		switch name {
+		case "project-mc":
+			return &devstep.DockerContainerStatus{ContainerID: "mc-id", Running: false}, nil
+		case "project-redis":
+			return &devstep.DockerContainerStatus{ContainerID: "redis-id", Running: true}, nil
+		}
+		return nil, nil
+	}
+	var startedIds []string
+	clientMock.StartContainerFunc = func(id string) error {
+		startedIds = append(startedIds, id)
+		return nil
+	}
+	var runOpts []*devstep.DockerRunOpts
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = append(runOpts, o)
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+
+	err = project.Build(clientMock, &devstep.DockerRunOpts{})
+	ok(t, err)
+
+	equals(t, 2, len(runOpts))
+
+	serviceOpts := runOpts[0]
+	equals(t, "project-db", serviceOpts.Name)
+	equals(t, "postgres:9.4", serviceOpts.Image)
+	assert(t, serviceOpts.Detach, "Service container was not detached")
+	equals(t, "secret", serviceOpts.Env["POSTGRES_PASSWORD"])
+	equals(t, []string{"5432:5432"}, serviceOpts.Publish)
+
+	equals(t, []string{"mc-id"}, startedIds)
+
+	buildOpts := runOpts[1]
+	equals(t, "repo/name:tag", buildOpts.Image)
+	equals(t, []string{"project-db:db", "project-mc:mc", "project-redis:redis"}, buildOpts.Links)
+}
+
+func Test_RunWithErrorStartingServices(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage: "repo/name:tag",
+		Services: []*devstep.ServiceConfig{
+			&devstep.ServiceConfig{Alias: "db", Name: "project-db", Image: "postgres:9.4"},
+		},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ContainerStatusFunc = func(name string) (*devstep.DockerContainerStatus, error) {
+		return &devstep.DockerContainerStatus{ContainerID: "db-id"}, nil
+	}
+	clientMock.StartContainerFunc = func(id string) error {
+		return errors.New("BOOM!")
+	}
+	ran := false
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		ran = true
+		return &devstep.DockerRunResult{}, nil
+	}
+
+	_, err = project.Run(clientMock, &devstep.DockerRunOpts{Cmd: []string{"bash"}})
+	assert(t, err != nil, "No error raised")
+	assert(t, !ran, "Project container was started")
+}
+
+func Test_StopServices(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		Services: []*devstep.ServiceConfig{
+			&devstep.ServiceConfig{Alias: "db", Name: "project-db", Image: "postgres:9.4"},
+			&devstep.ServiceConfig{Alias: "mc", Name: "project-mc", Image: "memcached"},
+			&devstep.ServiceConfig{Alias: "redis", Name: "project-redis", Image: "redis"},
+		},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ContainerStatusFunc = func(name string) (*devstep.DockerContainerStatus, error) {
+		switch name {
+		case "project-mc":
+			return &devstep.DockerContainerStatus{ContainerID: "mc-id", Running: false}, nil
+		case "project-redis":
+			return &devstep.DockerContainerStatus{ContainerID: "redis-id", Running: true}, nil
+		}
+		return nil, nil
+	}
+	var stoppedIds []string
+	clientMock.StopContainerFunc = func(id string) error {
+		stoppedIds = append(stoppedIds, id)
+		return nil
+	}
+	var removedIds []string
+	clientMock.RemoveContainerFunc = func(id string) error {
+		removedIds = append(removedIds, id)
+		return nil
+	}
+
+	ok(t, project.StopServices(clientMock, false))
+	equals(t, []string{"redis-id"}, stoppedIds)
+	equals(t, 0, len(removedIds))
+
+	stoppedIds = nil
+	ok(t, project.StopServices(clientMock, true))
+	equals(t, []string{"redis-id"}, stoppedIds)
+	equals(t, []string{"mc-id", "redis-id"}, removedIds)
+}
+
+func Test_ServicesStatus(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		Services: []*devstep.ServiceConfig{
+			&devstep.ServiceConfig{Alias: "db", Name: "project-db", Image: "postgres:9.4"},
+			&devstep.ServiceConfig{Alias: "redis", Name: "project-redis", Image: "redis"},
+		},
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.ContainerStatusFunc = func(name string) (*devstep.DockerContainerStatus, error) {
+		if name == "project-redis" {
+			return &devstep.DockerContainerStatus{ContainerID: "redis-id", Running: true}, nil
+		}
+		return nil, nil
+	}
+
+	statuses, err := project.ServicesStatus(clientMock)
+	ok(t, err)
+
+	equals(t, 2, len(statuses))
+	equals(t, "db", statuses[0].Alias)
+	equals(t, "", statuses[0].ContainerID)
+	assert(t, !statuses[0].Running, "Service is running")
+	equals(t, "redis", statuses[1].Alias)
+	equals(t, "redis-id", statuses[1].ContainerID)
+	assert(t, statuses[1].Running, "Service is not running")
+}
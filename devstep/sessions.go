@@ -0,0 +1,139 @@
+package devstep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Where the state of hack sessions is kept on the host
+var SessionsDir = filepath.Join(os.TempDir(), "devstep-sessions")
+
+// Keeps track of the `devstep hack` sessions attached to the hack container
+// of a project. Concurrent invocations coordinate through a lock file so that
+// they agree on a single container and the last session to end is the one
+// that removes it.
+type sessionManager struct {
+	dir string
+}
+
+// A hack session registered for a container
+type hackSession struct {
+	ContainerID string
+	path        string
+}
+
+func newSessionManager(projectRoot string) *sessionManager {
+	hash := sha256.Sum256([]byte(projectRoot))
+	return &sessionManager{dir: filepath.Join(SessionsDir, hex.EncodeToString(hash[:])[:16])}
+}
+
+// Blocks until no other devstep process is managing the project sessions,
+// the returned function releases the lock
+func (m *sessionManager) lock() (func(), error) {
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return nil, errors.New("Error creating sessions dir:\n  " + err.Error())
+	}
+
+	file, err := os.OpenFile(filepath.Join(m.dir, "lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.New("Error opening sessions lock:\n  " + err.Error())
+	}
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, errors.New("Error locking sessions:\n  " + err.Error())
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// Records that the current process is using the container, must be called
+// with the lock held
+func (m *sessionManager) register(containerID string) (*hackSession, error) {
+	containerDir := filepath.Join(m.dir, containerID)
+	if err := os.MkdirAll(containerDir, 0700); err != nil {
+		return nil, errors.New("Error registering session:\n  " + err.Error())
+	}
+
+	// Session files are named after the pid of the process that owns them
+	file, err := ioutil.TempFile(containerDir, strconv.Itoa(os.Getpid())+"-")
+	if err != nil {
+		return nil, errors.New("Error registering session:\n  " + err.Error())
+	}
+	file.Close()
+
+	return &hackSession{ContainerID: containerID, path: file.Name()}, nil
+}
+
+// Must be called with the lock held
+func (m *sessionManager) unregister(session *hackSession) error {
+	if err := os.Remove(session.path); err != nil && !os.IsNotExist(err) {
+		return errors.New("Error unregistering session:\n  " + err.Error())
+	}
+	return nil
+}
+
+// Counts the sessions registered for the container whose processes are
+// still alive, cleaning up the ones left behind by processes that got
+// killed. Must be called with the lock held.
+func (m *sessionManager) activeSessions(containerID string) (int, error) {
+	containerDir := filepath.Join(m.dir, containerID)
+	entries, err := ioutil.ReadDir(containerDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.New("Error reading sessions:\n  " + err.Error())
+	}
+
+	count := 0
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(strings.SplitN(entry.Name(), "-", 2)[0])
+		if err != nil {
+			continue
+		}
+		if processAlive(pid) {
+			count++
+		} else {
+			log.Info("Removing stale session of process %d", pid)
+			os.Remove(filepath.Join(containerDir, entry.Name()))
+		}
+	}
+	return count, nil
+}
+
+// Counts the live sessions registered for a container of a project without
+// creating the sessions dir when there is none
+func registeredSessions(projectRoot, containerID string) (int, error) {
+	sessions := newSessionManager(projectRoot)
+	if _, err := os.Stat(sessions.dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	unlock, err := sessions.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return sessions.activeSessions(containerID)
+}
+
+// Forgets about the sessions of a container that got removed, must be called
+// with the lock held
+func (m *sessionManager) forget(containerID string) {
+	os.RemoveAll(filepath.Join(m.dir, containerID))
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,282 @@
+package devstep_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_ConcurrentHackSessionsShareTheContainer(t *testing.T) {
+	defer useTempSessionsDir()()
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CacheDir:    "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	var mu sync.Mutex
+	runs := 0
+	removed := []string{}
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if runs > 0 && len(removed) == 0 {
+			return []string{"cid"}, nil
+		}
+		return []string{}, nil
+	}
+	clientMock.RemoveContainerFunc = func(id string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, id)
+		return nil
+	}
+	started := make(chan string)
+	release := make(chan struct{})
+	clientMock.ExecuteFunc = func(o *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		started <- o.Cmd[len(o.Cmd)-1]
+		<-release
+		return &devstep.DockerExecResult{}, nil
+	}
+
+	errs := make(chan error)
+	go func() { errs <- project.Hack(clientMock, nil) }()
+	equals(t, "/opt/devstep/bin/hack", <-started)
+	go func() { errs <- project.Hack(clientMock, nil) }()
+	equals(t, "bash", <-started)
+
+	release <- struct{}{}
+	ok(t, <-errs)
+	mu.Lock()
+	equals(t, 0, len(removed))
+	mu.Unlock()
+
+	release <- struct{}{}
+	ok(t, <-errs)
+	equals(t, 1, runs)
+	equals(t, []string{"cid"}, removed)
+}
+
+func Test_ReusedHackSessionsStartOnTheCurrentDir(t *testing.T) {
+	defer useTempSessionsDir()()
+	first, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CacheDir:    "/cache/path/on/host",
+	})
+	ok(t, err)
+	second, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CurrentDir:  "src/models",
+		CacheDir:    "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	var mu sync.Mutex
+	runs := 0
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if runs > 0 {
+			return []string{"cid"}, nil
+		}
+		return []string{}, nil
+	}
+	started := make(chan []string)
+	release := make(chan struct{})
+	clientMock.ExecuteFunc = func(o *devstep.DockerExecOpts) (*devstep.DockerExecResult, error) {
+		started <- o.Cmd
+		<-release
+		return &devstep.DockerExecResult{}, nil
+	}
+
+	errs := make(chan error)
+	go func() { errs <- first.Hack(clientMock, nil) }()
+	equals(t, []string{"/opt/devstep/bin/exec-entrypoint", "/opt/devstep/bin/hack"}, <-started)
+	go func() { errs <- second.Hack(clientMock, nil) }()
+	cmd := <-started
+	equals(t, `cd '/path/on/guest/src/models' && exec "$@"`, cmd[2])
+	equals(t, "bash", cmd[len(cmd)-1])
+
+	release <- struct{}{}
+	ok(t, <-errs)
+	release <- struct{}{}
+	ok(t, <-errs)
+}
+
+func Test_HackCanKeepTheContainer(t *testing.T) {
+	defer useTempSessionsDir()()
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CacheDir:    "/cache/path/on/host",
+	})
+	ok(t, err)
+	project.Config().KeepHackContainer = true
+
+	var runOpts *devstep.DockerRunOpts
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		runOpts = o
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.RemoveContainerFunc = func(id string) error {
+		t.Fatal("Container was removed")
+		return nil
+	}
+
+	ok(t, project.Hack(clientMock, nil))
+	equals(t, "true", runOpts.Labels[devstep.LabelKeep])
+}
+
+func Test_HackKeepsContainersStartedByOtherSessions(t *testing.T) {
+	defer useTempSessionsDir()()
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CacheDir:    "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	var filters map[string]string
+	clientMock.FindContainersFunc = func(labels map[string]string) ([]*devstep.DockerContainer, error) {
+		filters = labels
+		return []*devstep.DockerContainer{{ID: "cid", Labels: labels}}, nil
+	}
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		return []string{"cid"}, nil
+	}
+	clientMock.RemoveContainerFunc = func(id string) error {
+		t.Fatal("Container was removed")
+		return nil
+	}
+
+	ok(t, project.Hack(clientMock, nil))
+	equals(t, "true", filters[devstep.LabelKeep])
+	equals(t, "/path/on/host", filters[devstep.LabelProject])
+}
+
+func Test_HackReportsErrorsWhenEndingSessions(t *testing.T) {
+	defer useTempSessionsDir()()
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage: "source/image:tag",
+		BaseImage:   "repo/name:latest",
+		HostDir:     "/path/on/host",
+		GuestDir:    "/path/on/guest",
+		CacheDir:    "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.RunFunc = func(o *devstep.DockerRunOpts) (*devstep.DockerRunResult, error) {
+		return &devstep.DockerRunResult{ContainerID: "cid"}, nil
+	}
+	clientMock.ContainerHasExecInstancesRunningFunc = func(string) (bool, error) {
+		return false, errors.New("Error inspecting exec instances")
+	}
+	clientMock.RemoveContainerFunc = func(id string) error {
+		t.Fatal("Container was removed")
+		return nil
+	}
+
+	err = project.Hack(clientMock, nil)
+	equals(t, errors.New("Error inspecting exec instances"), err)
+}
+
+// Points the sessions dir to a temporary dir, returning a function that
+// restores it
+func useTempSessionsDir() func() {
+	previous := devstep.SessionsDir
+	tempDir, _ := ioutil.TempDir("", "devstep-sessions-")
+	devstep.SessionsDir = tempDir
+	return func() {
+		devstep.SessionsDir = previous
+		os.RemoveAll(tempDir)
+	}
+}
+
+func Test_HackIgnoresKeepLabelsInheritedFromImages(t *testing.T) {
+	defer useTempSessionsDir()()
+	server, client := newTestClient()
+	defer server.Close()
+
+	// Images commited from kept containers by older versions carry the label
+	image := server.AddImage("repo/name:latest")
+	image.Config.Labels = map[string]string{devstep.LabelKeep: "true", devstep.LabelRole: "hack"}
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		SourceImage:    "source/image:tag",
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+		GuestDir:       "/path/on/guest",
+		CacheDir:       "/cache/path/on/host",
+	})
+	ok(t, err)
+
+	captureOutput(func() {
+		err = project.Hack(client, nil)
+	})
+	ok(t, err)
+	equals(t, 0, len(server.Containers()))
+}
+
+func Test_CommitedImagesDoNotKeepContainerLabels(t *testing.T) {
+	server, client := newTestClient()
+	defer server.Close()
+
+	container := server.AddContainer("hack-container", "repo/name:latest")
+	container.Config.Labels = map[string]string{
+		devstep.LabelKeep:     "true",
+		devstep.LabelAttached: "true",
+		devstep.LabelRole:     "hack",
+	}
+
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	captureOutput(func() {
+		err = project.Commit(client, "hack-container", &devstep.CommitOpts{Tag: "snapshot"})
+	})
+	ok(t, err)
+
+	labels := server.Image("repo/name:snapshot").Config.Labels
+	equals(t, "", labels[devstep.LabelKeep])
+	equals(t, "", labels[devstep.LabelAttached])
+	equals(t, "", labels[devstep.LabelRole])
+}
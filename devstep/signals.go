@@ -0,0 +1,65 @@
+package devstep
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Returned when devstep gets interrupted while a container is running, the
+// container might have exited cleanly but its work should not be trusted
+type InterruptedError struct {
+	Signal os.Signal
+}
+
+func (e *InterruptedError) Error() string {
+	return "Interrupted by " + e.Signal.String()
+}
+
+// Relays the SIGINT / SIGTERM received by devstep to the channel until the
+// returned function gets called, tests replace it to simulate signals
+var notifySignals = func(signals chan<- os.Signal) func() {
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	return func() { signal.Stop(signals) }
+}
+
+// Calls the handler for each SIGINT / SIGTERM received instead of exiting
+// until the returned function gets called, which returns the first signal
+// received (if any)
+func handleSignals(handler func(os.Signal)) func() os.Signal {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	var mu sync.Mutex
+	var received os.Signal
+
+	stopNotifying := notifySignals(signals)
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case sig := <-signals:
+				mu.Lock()
+				if received == nil {
+					received = sig
+				}
+				mu.Unlock()
+				handler(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() os.Signal {
+		stopNotifying()
+		close(done)
+		<-stopped
+
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}
+}
@@ -0,0 +1,167 @@
+package devstep
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// The differences between two snapshots of the environment, see
+// Project.CompareSnapshots
+type SnapshotDiff struct {
+	From     string                     `json:"from"`
+	To       string                     `json:"to"`
+	Files    map[string][]*DockerChange `json:"files"`    // changes grouped by top level dir, like `/usr`
+	Metadata []*MetadataChange          `json:"metadata"` // sorted by key
+	Ignored  int                        `json:"ignored"`  // number of changes matched by the ignore list
+}
+
+// A setting of the environment (like `env.PATH`) that changed between two
+// snapshots
+type MetadataChange struct {
+	Key  string `json:"key"`
+	Kind string `json:"kind"` // ChangeModified, ChangeAdded or ChangeDeleted
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// Top level dirs of the files that changed, sorted
+func (d *SnapshotDiff) Dirs() []string {
+	dirs := []string{}
+	for dir := range d.Files {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Compares two snapshots of the environment. Snapshots are tags of the
+// project repository, `hack` for the running hack container (unless there is
+// a tag with that name) or the name of any other container.
+func (p *project) CompareSnapshots(client DockerClient, from, to string) (*SnapshotDiff, error) {
+	fromName, fromContents, err := p.snapshotContents(client, from)
+	if err != nil {
+		return nil, err
+	}
+	toName, toContents, err := p.snapshotContents(client, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{
+		From:     fromName,
+		To:       toName,
+		Files:    make(map[string][]*DockerChange),
+		Metadata: []*MetadataChange{},
+	}
+	for _, change := range compareFiles(fromContents.Files, toContents.Files) {
+		if changeIgnored(p.ignoredChanges(), change.Path) {
+			diff.Ignored++
+			continue
+		}
+		file, found := toContents.Files[change.Path]
+		if !found {
+			file = fromContents.Files[change.Path]
+		}
+		dir := topLevelDir(change.Path, file.Mode.IsDir())
+		diff.Files[dir] = append(diff.Files[dir], change)
+	}
+	diff.Metadata = compareMetadata(fromContents.Metadata, toContents.Metadata)
+	return diff, nil
+}
+
+func (p *project) snapshotContents(client DockerClient, name string) (string, *DockerContents, error) {
+	if name == "" {
+		return "", nil, errors.New("A snapshot tag or container name must be provided")
+	}
+
+	image := p.RepositoryName + ":" + name
+	info, err := client.InspectImage(image)
+	if err != nil {
+		return "", nil, err
+	}
+	if info != nil {
+		contents, err := client.ImageContents(image)
+		return image, contents, err
+	}
+
+	containerID := ""
+	if name == "hack" {
+		containers, err := client.ListContainers(p.roleFilter(RoleHack))
+		if err != nil {
+			return "", nil, err
+		}
+		if len(containers) == 0 {
+			return "", nil, errors.New("The hack container is not running")
+		}
+		containerID = containers[0]
+	} else if containerID, err = client.LookupContainerID(name); err != nil {
+		return "", nil, errors.New("'" + name + "' is neither a snapshot of '" + p.RepositoryName + "' nor a container")
+	}
+
+	contents, err := client.ContainerContents(containerID)
+	return name, contents, err
+}
+
+// Lists the files that got added, removed or modified, sorted by path
+func compareFiles(from, to map[string]*DockerFile) []*DockerChange {
+	changes := []*DockerChange{}
+	for path, file := range to {
+		previous, found := from[path]
+		if !found {
+			changes = append(changes, &DockerChange{Path: path, Kind: ChangeAdded})
+		} else if previous.Mode != file.Mode || previous.Size != file.Size || previous.Checksum != file.Checksum {
+			changes = append(changes, &DockerChange{Path: path, Kind: ChangeModified})
+		}
+	}
+	for path := range from {
+		if _, found := to[path]; !found {
+			changes = append(changes, &DockerChange{Path: path, Kind: ChangeDeleted})
+		}
+	}
+	sort.Sort(changesByPath(changes))
+	return changes
+}
+
+func compareMetadata(from, to map[string]string) []*MetadataChange {
+	keys := map[string]bool{}
+	for key := range from {
+		keys[key] = true
+	}
+	for key := range to {
+		keys[key] = true
+	}
+
+	changes := []*MetadataChange{}
+	for key := range keys {
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+		change := &MetadataChange{Key: key, Kind: ChangeModified, From: fromValue, To: toValue}
+		switch {
+		case !inFrom:
+			change.Kind = ChangeAdded
+		case !inTo:
+			change.Kind = ChangeDeleted
+		case fromValue == toValue:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	sort.Sort(metadataChangesByKey(changes))
+	return changes
+}
+
+// Files placed directly on the root dir are grouped under `/`
+func topLevelDir(path string, isDir bool) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) < 2 && !isDir {
+		return "/"
+	}
+	return "/" + parts[0]
+}
+
+type metadataChangesByKey []*MetadataChange
+
+func (m metadataChangesByKey) Len() int           { return len(m) }
+func (m metadataChangesByKey) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m metadataChangesByKey) Less(i, j int) bool { return m[i].Key < m[j].Key }
@@ -0,0 +1,145 @@
+package devstep_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func file(path, checksum string) *devstep.DockerFile {
+	return &devstep.DockerFile{Path: path, Mode: 0644, Size: int64(len(checksum)), Checksum: checksum}
+}
+
+func dir(path string) *devstep.DockerFile {
+	return &devstep.DockerFile{Path: path, Mode: os.ModeDir | 0755}
+}
+
+func Test_CompareSnapshots(t *testing.T) {
+	contents := map[string]*devstep.DockerContents{
+		"repo/name:20160101000000": {
+			Files: map[string]*devstep.DockerFile{
+				"/usr":             dir("/usr"),
+				"/usr/bin":         dir("/usr/bin"),
+				"/usr/bin/ruby":    file("/usr/bin/ruby", "2.2"),
+				"/usr/bin/node":    file("/usr/bin/node", "0.12"),
+				"/etc/motd":        file("/etc/motd", "hello"),
+				"/home/devstep":    dir("/home/devstep"),
+				"/.dockerenv":      file("/.dockerenv", ""),
+				"/opt/legacy/tool": file("/opt/legacy/tool", "tool"),
+			},
+			Metadata: map[string]string{"env.PATH": "/usr/bin", "env.OLD": "1", "cmd": "bash"},
+		},
+		"repo/name:latest": {
+			Files: map[string]*devstep.DockerFile{
+				"/usr":          dir("/usr"),
+				"/usr/bin":      dir("/usr/bin"),
+				"/usr/bin/ruby": file("/usr/bin/ruby", "2.3"),
+				"/usr/bin/node": file("/usr/bin/node", "0.12"),
+				"/usr/bin/yarn": file("/usr/bin/yarn", "0.1"),
+				"/etc/motd":     file("/etc/motd", "hello"),
+				"/home/devstep": &devstep.DockerFile{Path: "/home/devstep", Mode: os.ModeDir | 0700},
+				"/.dockerenv":   file("/.dockerenv", "new"),
+				"/srv":          dir("/srv"),
+			},
+			Metadata: map[string]string{"env.PATH": "/usr/local/bin:/usr/bin", "env.NEW": "", "cmd": "bash"},
+		},
+	}
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.InspectImageFunc = func(name string) (*devstep.DockerImage, error) {
+		if _, found := contents[name]; !found {
+			return nil, nil
+		}
+		return &devstep.DockerImage{ID: name}, nil
+	}
+	clientMock.ImageContentsFunc = func(name string) (*devstep.DockerContents, error) {
+		return contents[name], nil
+	}
+	clientMock.ContainerContentsFunc = func(id string) (*devstep.DockerContents, error) {
+		return contents[id], nil
+	}
+	clientMock.LookupContainerIDFunc = func(name string) (string, error) {
+		return "", errors.New("No such container: " + name)
+	}
+
+	diff, err := project.CompareSnapshots(clientMock, "20160101000000", "latest")
+	ok(t, err)
+
+	equals(t, "repo/name:20160101000000", diff.From)
+	equals(t, "repo/name:latest", diff.To)
+	equals(t, []string{"/", "/opt", "/srv", "/usr"}, diff.Dirs())
+	equals(t, []*devstep.DockerChange{{Path: "/.dockerenv", Kind: devstep.ChangeModified}}, diff.Files["/"])
+	equals(t, []*devstep.DockerChange{{Path: "/opt/legacy/tool", Kind: devstep.ChangeDeleted}}, diff.Files["/opt"])
+	equals(t, []*devstep.DockerChange{{Path: "/srv", Kind: devstep.ChangeAdded}}, diff.Files["/srv"])
+	equals(t, []*devstep.DockerChange{
+		{Path: "/usr/bin/ruby", Kind: devstep.ChangeModified},
+		{Path: "/usr/bin/yarn", Kind: devstep.ChangeAdded},
+	}, diff.Files["/usr"])
+	equals(t, 1, diff.Ignored)
+
+	equals(t, []*devstep.MetadataChange{
+		{Key: "env.NEW", Kind: devstep.ChangeAdded},
+		{Key: "env.OLD", Kind: devstep.ChangeDeleted, From: "1"},
+		{Key: "env.PATH", Kind: devstep.ChangeModified, From: "/usr/bin", To: "/usr/local/bin:/usr/bin"},
+	}, diff.Metadata)
+}
+
+func Test_CompareSnapshotWithHackContainer(t *testing.T) {
+	contents := map[string]*devstep.DockerContents{
+		"repo/name:latest": {
+			Files:    map[string]*devstep.DockerFile{"/etc/motd": file("/etc/motd", "hello")},
+			Metadata: map[string]string{},
+		},
+		"hack-id": {
+			Files:    map[string]*devstep.DockerFile{"/etc/motd": file("/etc/motd", "hi")},
+			Metadata: map[string]string{},
+		},
+	}
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		RepositoryName: "repo/name",
+		HostDir:        "/path/on/host",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.InspectImageFunc = func(name string) (*devstep.DockerImage, error) {
+		if _, found := contents[name]; !found {
+			return nil, nil
+		}
+		return &devstep.DockerImage{ID: name}, nil
+	}
+	clientMock.ImageContentsFunc = func(name string) (*devstep.DockerContents, error) {
+		return contents[name], nil
+	}
+	clientMock.ContainerContentsFunc = func(id string) (*devstep.DockerContents, error) {
+		return contents[id], nil
+	}
+	clientMock.LookupContainerIDFunc = func(name string) (string, error) {
+		return "", errors.New("No such container: " + name)
+	}
+	clientMock.ListContainersFunc = func(labels map[string]string) ([]string, error) {
+		equals(t, devstep.RoleHack, labels[devstep.LabelRole])
+		return []string{"hack-id"}, nil
+	}
+
+	diff, err := project.CompareSnapshots(clientMock, "latest", "hack")
+	ok(t, err)
+	equals(t, "hack", diff.To)
+	equals(t, []*devstep.DockerChange{{Path: "/etc/motd", Kind: devstep.ChangeModified}}, diff.Files["/etc"])
+
+	clientMock.ListContainersFunc = func(map[string]string) ([]string, error) {
+		return []string{}, nil
+	}
+	_, err = project.CompareSnapshots(clientMock, "latest", "hack")
+	equals(t, "The hack container is not running", errString(err))
+
+	_, err = project.CompareSnapshots(clientMock, "latest", "unknown")
+	equals(t, "'unknown' is neither a snapshot of 'repo/name' nor a container", errString(err))
+}
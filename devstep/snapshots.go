@@ -0,0 +1,84 @@
+package devstep
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// An image commited for the project, like the ones tagged with a timestamp
+// after each build
+type Snapshot struct {
+	Tag    string
+	Latest bool // whether `latest` points to the same image
+	*DockerImage
+}
+
+// Lists the images of the project repository, newest first
+func (p *project) Snapshots(client DockerClient) ([]*Snapshot, error) {
+	tags, err := client.ListTags(p.RepositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := client.InspectImage(p.RepositoryName + ":latest")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*Snapshot{}
+	for _, tag := range tags {
+		if tag == "latest" {
+			continue
+		}
+		image, err := client.InspectImage(p.RepositoryName + ":" + tag)
+		if err != nil {
+			return nil, err
+		}
+		if image == nil {
+			continue
+		}
+		snapshots = append(snapshots, &Snapshot{
+			Tag:         tag,
+			Latest:      latest != nil && latest.ID == image.ID,
+			DockerImage: image,
+		})
+	}
+
+	sort.Sort(snapshotsByDate(snapshots))
+	return snapshots, nil
+}
+
+// Points `latest` to a previous snapshot so that it gets used as the base
+// image from now on
+func (p *project) Rollback(client DockerClient, tag string) error {
+	if tag == "" || tag == "latest" {
+		return errors.New("A snapshot tag other than 'latest' must be provided")
+	}
+
+	image := p.RepositoryName + ":" + tag
+	info, err := client.InspectImage(image)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return errors.New("Snapshot '" + image + "' does not exist")
+	}
+
+	fmt.Printf("==> Tagging '%s' as '%s:latest'\n", image, p.RepositoryName)
+	if err = client.TagImage(image, p.RepositoryName, "latest"); err != nil {
+		return errors.New("Error tagging image:\n  " + err.Error())
+	}
+	return nil
+}
+
+type snapshotsByDate []*Snapshot
+
+func (s snapshotsByDate) Len() int      { return len(s) }
+func (s snapshotsByDate) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByDate) Less(i, j int) bool {
+	if !s[i].Created.Equal(s[j].Created) {
+		return s[i].Created.After(s[j].Created)
+	}
+	return s[i].Tag > s[j].Tag
+}
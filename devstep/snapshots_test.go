@@ -0,0 +1,84 @@
+package devstep_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fgrehm/devstep-cli/devstep"
+)
+
+func Test_Snapshots(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+	})
+	ok(t, err)
+
+	images := map[string]*devstep.DockerImage{
+		"repo/name:latest":         {ID: "c", Created: time.Date(2015, 1, 3, 0, 0, 1, 0, time.UTC), Size: 4096},
+		"repo/name:20150101000000": {ID: "a", Created: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), Size: 1024},
+		"repo/name:custom":         {ID: "b", Created: time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC), Size: 2048},
+		"repo/name:20150103000000": {ID: "c", Created: time.Date(2015, 1, 3, 0, 0, 1, 0, time.UTC), Size: 4096},
+	}
+	clientMock := NewMockClient()
+	clientMock.ListTagsFunc = func(string) ([]string, error) {
+		return []string{"latest", "20150101000000", "custom", "20150103000000"}, nil
+	}
+	clientMock.InspectImageFunc = func(name string) (*devstep.DockerImage, error) {
+		return images[name], nil
+	}
+
+	snapshots, err := project.Snapshots(clientMock)
+	ok(t, err)
+
+	equals(t, 3, len(snapshots))
+	equals(t, "20150103000000", snapshots[0].Tag)
+	equals(t, "custom", snapshots[1].Tag)
+	equals(t, "20150101000000", snapshots[2].Tag)
+	equals(t, int64(2048), snapshots[1].Size)
+	assert(t, snapshots[0].Latest, "Expected the newest snapshot to be the latest")
+	assert(t, !snapshots[1].Latest, "Expected the custom snapshot not to be the latest")
+}
+
+func Test_Rollback(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	var tagged []string
+	clientMock.TagImageFunc = func(image, repositoryName, tag string) error {
+		tagged = []string{image, repositoryName, tag}
+		return nil
+	}
+
+	err = project.Rollback(clientMock, "20150101000000")
+	ok(t, err)
+	equals(t, []string{"repo/name:20150101000000", "repo/name", "latest"}, tagged)
+}
+
+func Test_RollbackToUnknownSnapshot(t *testing.T) {
+	project, err := devstep.NewProject(&devstep.ProjectConfig{
+		BaseImage:      "repo/name:latest",
+		RepositoryName: "repo/name",
+	})
+	ok(t, err)
+
+	clientMock := NewMockClient()
+	clientMock.InspectImageFunc = func(string) (*devstep.DockerImage, error) {
+		return nil, nil
+	}
+	clientMock.TagImageFunc = func(string, string, string) error {
+		t.Fatal("Image was tagged")
+		return nil
+	}
+
+	err = project.Rollback(clientMock, "unknown")
+	equals(t, errors.New("Snapshot 'repo/name:unknown' does not exist"), err)
+
+	err = project.Rollback(clientMock, "latest")
+	assert(t, err != nil, "Rolled back to latest")
+}
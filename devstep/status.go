@@ -0,0 +1,35 @@
+package devstep
+
+import (
+	"sort"
+)
+
+// The containers and images devstep created for a project
+type ProjectStatus struct {
+	Containers []*DockerContainer
+	Snapshots  []*Snapshot
+}
+
+// Lists the containers created for the project, running or not, along with
+// its images
+func (p *project) Status(client DockerClient) (*ProjectStatus, error) {
+	containers, err := client.FindContainers(map[string]string{LabelProject: p.HostDir})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(containersByDate(containers))
+
+	snapshots, err := p.Snapshots(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProjectStatus{Containers: containers, Snapshots: snapshots}, nil
+}
+
+// Sorts containers from the newest to the oldest
+type containersByDate []*DockerContainer
+
+func (c containersByDate) Len() int           { return len(c) }
+func (c containersByDate) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c containersByDate) Less(i, j int) bool { return c[i].Created.After(c[j].Created) }
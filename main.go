@@ -14,14 +14,24 @@ func main() {
 	app.Author = "Fábio Rehm"
 	app.Email = "fgrehm@gmail.com"
 	app.Usage = "development environments made easy"
-	app.Version = "1.0.0"
+	app.Version = devstep.Version
 	app.EnableBashCompletion = true
 	app.Flags = []cli.Flag{
 		cli.StringFlag{Name: "log-level, l", Value: "warning", Usage: "log level", EnvVar: "DEVSTEP_LOG"},
+		cli.StringFlag{Name: "profile", Usage: "configuration profile to use", EnvVar: "DEVSTEP_PROFILE"},
 	}
 	app.Before = func(c *cli.Context) error {
-		commands.InitDevstepEnv()
-		return devstep.SetLogLevel(c.GlobalString("log-level"))
+		if err := devstep.SetLogLevel(c.GlobalString("log-level")); err != nil {
+			return err
+		}
+		// The config command loads the configuration by itself so that it can
+		// report problems with it
+		if c.Args().First() == "config" {
+			commands.InitDevstepClient(c.GlobalString("profile"))
+		} else {
+			commands.InitDevstepEnv(c.GlobalString("profile"))
+		}
+		return nil
 	}
 
 	containerName := os.Getenv("DEVSTEP_CONTAINER_NAME")
@@ -30,18 +40,31 @@ func main() {
 			commands.BootstrapCmd,
 			commands.BuildCmd,
 			commands.CleanCmd,
+			commands.ConfigCmd,
+			commands.DiffCmd,
 			commands.ExecCmd,
+			commands.ExportCmd,
+			commands.GcCmd,
 			commands.HackCmd,
+			commands.ImportCmd,
 			commands.InfoCmd,
 			commands.InitCmd,
 			commands.PristineCmd,
+			commands.PruneCmd,
+			commands.PullCmd,
+			commands.PushCmd,
+			commands.RollbackCmd,
 			commands.RunCmd,
+			commands.ServicesCmd,
+			commands.SnapshotsCmd,
+			commands.StatusCmd,
 		}
 	} else { // inside container
 		app.Commands = []cli.Command{
 			commands.CommitCmd,
 			commands.InfoCmd,
 			commands.InitCmd,
+			commands.SnapshotsCmd,
 		}
 	}
 